@@ -0,0 +1,201 @@
+package lzma
+
+import (
+	"fmt"
+	"io"
+)
+
+// AnalysisWindow is the size of the regions reported by Analyze.
+const AnalysisWindow = 64 << 10
+
+// DistRanges gives the upper bounds of the distance ranges used by
+// WindowStats.MatchCoverage.
+var DistRanges = [numDistRanges]int64{64, 4 << 10, 64 << 10, 1 << 20, MaxDictCap}
+
+const numDistRanges = 5
+
+// WindowStats describes a region of the input analyzed by Analyze.
+type WindowStats struct {
+	Offset int64
+	Len    int
+	// Entropy is the order-0 entropy in bits per byte.
+	Entropy float64
+	// LiteralCoverage, MatchCoverage and RepCoverage give the fractions of
+	// the bytes encoded as literals, by new matches in the distance ranges
+	// of DistRanges and by matches with repeated distances.
+	LiteralCoverage float64
+	MatchCoverage   [numDistRanges]float64
+	RepCoverage     float64
+	// RepFrequency is the fraction of the matches that use a repeated
+	// distance.
+	RepFrequency float64
+	// Filter suggests a filter for the region: none, x86 or delta:n.
+	Filter string
+}
+
+// windowCounts collects the operations of a window.
+type windowCounts struct {
+	literals int64
+	matches  [numDistRanges]int64
+	reps     int64
+	nMatches int64
+	nReps    int64
+}
+
+// distRange returns the index of the range of DistRanges containing dist.
+func distRange(dist int64) int {
+	for i, r := range DistRanges {
+		if dist <= r {
+			return i
+		}
+	}
+	return numDistRanges - 1
+}
+
+// analyzer assigns the operations traced by the encoder to the windows.
+type analyzer struct {
+	stats  []WindowStats
+	counts []windowCounts
+}
+
+func (a *analyzer) addOp(t TraceOp) {
+	if t.Kind == OpEOS {
+		return
+	}
+	w := int(t.Pos / AnalysisWindow)
+	switch t.Kind {
+	case OpMatch:
+		a.counts[w].nMatches++
+	case OpRep0, OpRep1, OpRep2, OpRep3, OpShortRep:
+		a.counts[w].nReps++
+	}
+	pos, n := t.Pos, int64(t.Len)
+	for n > 0 {
+		w = int(pos / AnalysisWindow)
+		k := (int64(w)+1)*AnalysisWindow - pos
+		if k > n {
+			k = n
+		}
+		c := &a.counts[w]
+		switch t.Kind {
+		case OpLit, OpMatchedLit:
+			c.literals += k
+		case OpMatch:
+			c.matches[distRange(t.Distance)] += k
+		default:
+			c.reps += k
+		}
+		pos += k
+		n -= k
+	}
+}
+
+// finish computes the fractions of the window statistics.
+func (a *analyzer) finish() {
+	for i := range a.stats {
+		s, c := &a.stats[i], &a.counts[i]
+		n := float64(s.Len)
+		s.LiteralCoverage = float64(c.literals) / n
+		for j, m := range c.matches {
+			s.MatchCoverage[j] = float64(m) / n
+		}
+		s.RepCoverage = float64(c.reps) / n
+		if m := c.nMatches + c.nReps; m > 0 {
+			s.RepFrequency = float64(c.nReps) / float64(m)
+		}
+	}
+}
+
+// x86Threshold is the number of x86 call and jump instructions with
+// near targets per KiB from which the x86 filter is suggested.
+const x86Threshold = 2.0
+
+// x86Calls counts the relative x86 calls and jumps in p whose target
+// offset has a high byte of 0x00 or 0xff, as typical for code.
+func x86Calls(p []byte) int {
+	n := 0
+	for i := 0; i+4 < len(p); i++ {
+		if p[i] != 0xe8 && p[i] != 0xe9 {
+			continue
+		}
+		if b := p[i+4]; b == 0x00 || b == 0xff {
+			n++
+			i += 4
+		}
+	}
+	return n
+}
+
+// deltaEntropy returns the order-0 entropy of the differences between the
+// bytes of p that are dist bytes apart.
+func deltaEntropy(p []byte, dist int) float64 {
+	if len(p) <= dist {
+		return 8
+	}
+	d := make([]byte, len(p)-dist)
+	for i := range d {
+		d[i] = p[i+dist] - p[i]
+	}
+	return byteEntropy(d)
+}
+
+// suggestFilter proposes a filter for the data p given its entropy h.
+func suggestFilter(p []byte, h float64) string {
+	if float64(x86Calls(p))*1024/float64(len(p)+1) >= x86Threshold {
+		return "x86"
+	}
+	best, bestH := 0, h-1
+	for _, dist := range []int{1, 2, 3, 4, 8, 16} {
+		if dh := deltaEntropy(p, dist); dh < bestH {
+			best, bestH = dist, dh
+		}
+	}
+	if best > 0 {
+		return fmt.Sprintf("delta:%d", best)
+	}
+	return "none"
+}
+
+// Analyze reports entropy and the operations of the encoder configured by
+// cfg for every window of AnalysisWindow bytes read from r. The fields
+// Size, SizeInHeader, PatchSize and Trace of cfg are ignored.
+func Analyze(r io.Reader, cfg WriterConfig) ([]WindowStats, error) {
+	a := &analyzer{}
+	cfg.Size, cfg.SizeInHeader, cfg.PatchSize = 0, false, false
+	cfg.Trace = a.addOp
+	w, err := cfg.NewWriter(discardWriter{})
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, AnalysisWindow)
+	var off int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			p := buf[:n]
+			h := byteEntropy(p)
+			a.stats = append(a.stats, WindowStats{
+				Offset:  off,
+				Len:     n,
+				Entropy: h,
+				Filter:  suggestFilter(p, h),
+			})
+			a.counts = append(a.counts, windowCounts{})
+			off += int64(n)
+			if _, err := w.Write(p); err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	a.finish()
+	return a.stats, nil
+}
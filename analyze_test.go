@@ -0,0 +1,128 @@
+package lzma
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// x86Data returns random bytes with a relative call or jump every 100
+// bytes.
+func x86Data(n int) []byte {
+	p := make([]byte, n)
+	rand.New(rand.NewSource(7)).Read(p)
+	for i := 0; i+5 <= n; i += 100 {
+		p[i] = 0xe8 + byte(i/100%2)
+		p[i+4] = 0xff
+	}
+	return p
+}
+
+// rampData returns four interleaved byte ramps with different offsets.
+func rampData(n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(i/4 + 61*(i%4))
+	}
+	return p
+}
+
+func TestAnalyze(t *testing.T) {
+	data := testData(3*AnalysisWindow + 1000)
+	windows, err := Analyze(bytes.NewReader(data), WriterConfig{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if len(windows) != 4 {
+		t.Fatalf("got %d windows; want 4", len(windows))
+	}
+	for i, w := range windows {
+		if w.Offset != int64(i)*AnalysisWindow {
+			t.Errorf("window %d: offset %d", i, w.Offset)
+		}
+		sum := w.LiteralCoverage + w.RepCoverage
+		for _, m := range w.MatchCoverage {
+			sum += m
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("window %d: coverage sums to %g", i, sum)
+		}
+		if w.Entropy <= 0 || w.Entropy > 8 {
+			t.Errorf("window %d: entropy %g", i, w.Entropy)
+		}
+		if w.RepFrequency < 0 || w.RepFrequency > 1 {
+			t.Errorf("window %d: rep frequency %g", i, w.RepFrequency)
+		}
+	}
+	if n := windows[3].Len; n != 1000 {
+		t.Errorf("last window has %d bytes; want 1000", n)
+	}
+}
+
+func TestAnalyzeDistances(t *testing.T) {
+	// A block of 1000 random bytes repeats at distance 10000, which is in
+	// the third distance range. Matches are limited to 273 bytes, so the
+	// block is continued with rep0 matches.
+	data := make([]byte, 11000)
+	rand.New(rand.NewSource(8)).Read(data[:10000])
+	copy(data[10000:], data)
+	windows, err := Analyze(bytes.NewReader(data), WriterConfig{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	w := windows[0]
+	c := w.MatchCoverage
+	if n := c[2] * float64(len(data)); n < maxMatchLen {
+		t.Errorf("%.0f bytes covered by matches up to 64 KiB", n)
+	}
+	if n := (c[2] + w.RepCoverage) * float64(len(data)); n < 990 || n > 1100 {
+		t.Errorf("%.0f bytes covered by matches; want about 1000", n)
+	}
+	if c[3] != 0 || c[4] != 0 {
+		t.Errorf("matches beyond 64 KiB: %v", c)
+	}
+}
+
+func TestDistRange(t *testing.T) {
+	tests := []struct {
+		dist int64
+		want int
+	}{
+		{1, 0}, {64, 0}, {65, 1}, {4 << 10, 1}, {4<<10 + 1, 2},
+		{64 << 10, 2}, {1 << 20, 3}, {1<<20 + 1, 4}, {MaxDictCap, 4},
+	}
+	for _, tc := range tests {
+		if got := distRange(tc.dist); got != tc.want {
+			t.Errorf("distRange(%d) = %d; want %d", tc.dist, got,
+				tc.want)
+		}
+	}
+}
+
+func TestSuggestFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"text", testData(AnalysisWindow), "none"},
+		{"x86", x86Data(AnalysisWindow), "x86"},
+		{"ramps", rampData(AnalysisWindow), "delta:4"},
+	}
+	for _, tc := range tests {
+		h := byteEntropy(tc.data)
+		if got := suggestFilter(tc.data, h); got != tc.want {
+			t.Errorf("%s: got filter %s; want %s", tc.name, got,
+				tc.want)
+		}
+	}
+	windows, err := Analyze(bytes.NewReader(rampData(AnalysisWindow)),
+		WriterConfig{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if f := windows[0].Filter; f != "delta:4" {
+		t.Errorf("Analyze suggests %s for ramps; want delta:4", f)
+	}
+}
@@ -0,0 +1,105 @@
+package lzma
+
+// propertyCandidates are the properties tried by SelectProperties. The
+// default properties come first, so they win ties.
+var propertyCandidates = []Properties{
+	{LC: 3, LP: 0, PB: 2},
+	{LC: 0, LP: 0, PB: 0},
+	{LC: 4, LP: 0, PB: 0},
+	{LC: 0, LP: 1, PB: 1},
+	{LC: 0, LP: 2, PB: 2},
+	{LC: 1, LP: 2, PB: 2},
+	{LC: 3, LP: 2, PB: 2},
+	{LC: 0, LP: 3, PB: 3},
+}
+
+// autoWindowLen is the size of the sample windows and autoWindows their
+// maximum number. The Writer collects a sample of autoSampleLen bytes.
+const (
+	autoWindowLen = 1 << 16
+	autoWindows   = 3
+	autoSampleLen = autoWindows * autoWindowLen
+)
+
+// sampleWindows returns windows from the start, the middle and the end of
+// p. Small inputs are returned as a single window.
+func sampleWindows(p []byte) [][]byte {
+	if len(p) <= autoWindows*autoWindowLen {
+		if len(p) == 0 {
+			return nil
+		}
+		return [][]byte{p}
+	}
+	mid := (len(p) - autoWindowLen) / 2
+	return [][]byte{
+		p[:autoWindowLen],
+		p[mid : mid+autoWindowLen],
+		p[len(p)-autoWindowLen:],
+	}
+}
+
+// opsCost returns the number of bits required to encode ops with the
+// properties p.
+func opsCost(ops []Op, p Properties, cfg WriterConfig) (float64, error) {
+	cfg.Properties = &p
+	w, err := cfg.NewWriter(discardWriter{})
+	if err != nil {
+		return 0, err
+	}
+	if _, err = w.WriteOps(ops); err != nil {
+		return 0, err
+	}
+	return w.e.re.bitPos(), nil
+}
+
+// SelectProperties trial-compresses windows of sample with a set of
+// candidate properties and returns the properties with the smallest
+// output. The match algorithm of cfg parses every window only once; the
+// operations found are then priced for each candidate.
+func SelectProperties(sample []byte, cfg WriterConfig) (Properties, error) {
+	cfg.Properties = nil
+	cfg.Size, cfg.SizeInHeader, cfg.PatchSize = 0, false, false
+	cfg.AutoProperties, cfg.Model, cfg.Trace = false, nil, nil
+	if err := cfg.Verify(); err != nil {
+		return Properties{}, err
+	}
+	costs := make([]float64, len(propertyCandidates))
+	for _, win := range sampleWindows(sample) {
+		c := cfg
+		if n := fitDictCap(len(win)); n < c.DictCap {
+			c.DictCap = n
+		}
+		ops, err := Tokenize(win, c)
+		if err != nil {
+			return Properties{}, err
+		}
+		for i, p := range propertyCandidates {
+			bits, err := opsCost(ops, p, c)
+			if err != nil {
+				return Properties{}, err
+			}
+			costs[i] += bits
+		}
+	}
+	best := 0
+	for i, c := range costs {
+		if c < costs[best] {
+			best = i
+		}
+	}
+	return propertyCandidates[best], nil
+}
+
+// autoProperties replaces the properties of the configuration by the
+// properties selected for sample if AutoProperties is set.
+func (c *WriterConfig) autoProperties(sample []byte) error {
+	if !c.AutoProperties {
+		return nil
+	}
+	p, err := SelectProperties(sample, *c)
+	if err != nil {
+		return err
+	}
+	c.Properties = &p
+	return nil
+}
@@ -0,0 +1,119 @@
+package lzma
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// contextData returns n bytes with few matches, whose high nibble is
+// determined by the high nibble of the previous byte.
+func contextData(n int) []byte {
+	rnd := rand.New(rand.NewSource(4))
+	p := make([]byte, n)
+	c := byte(0)
+	for i := range p {
+		c = byte((int(c>>4)*5+3)%16<<4 | rnd.Intn(16))
+		p[i] = c
+	}
+	return p
+}
+
+// recordData returns n bytes of repetitive 4-byte records.
+func recordData(n int) []byte {
+	rnd := rand.New(rand.NewSource(5))
+	p := make([]byte, 0, n)
+	for len(p) < n {
+		p = append(p, byte(rnd.Intn(64)), 0, byte(rnd.Intn(2)), 0x80)
+	}
+	return p[:n]
+}
+
+func TestSelectProperties(t *testing.T) {
+	p, err := SelectProperties(contextData(100000), WriterConfig{})
+	if err != nil {
+		t.Fatalf("SelectProperties: %v", err)
+	}
+	if p.LC < 3 || p.LP != 0 {
+		t.Errorf("literal-heavy data: got %+v; want LC >= 3, LP 0", p)
+	}
+	p, err = SelectProperties(recordData(100000), WriterConfig{})
+	if err != nil {
+		t.Fatalf("SelectProperties: %v", err)
+	}
+	if p.LP != 2 || p.PB != 2 {
+		t.Errorf("4-byte records: got %+v; want LP 2, PB 2", p)
+	}
+}
+
+func TestAutoProperties(t *testing.T) {
+	// The first write exceeds the sample.
+	data := append(recordData(250000), testData(50000)...)
+	var buf bytes.Buffer
+	w, err := WriterConfig{AutoProperties: true}.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, p := range [][]byte{data[:220000], data[220000:]} {
+		if _, err = w.Write(p); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// The properties byte is (PB*5+LP)*9+LC.
+	if lp := buf.Bytes()[0] / 9 % 5; lp != 2 {
+		t.Errorf("header has LP %d; want 2", lp)
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decoded data differs")
+	}
+}
+
+// compressChunks compresses data with AutoProperties using writes of
+// the given size.
+func compressChunks(t *testing.T, data []byte, size int) []byte {
+	var buf bytes.Buffer
+	w, err := WriterConfig{AutoProperties: true}.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err = w.Write(data[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		data = data[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAutoPropertiesSample(t *testing.T) {
+	// Only the first autoSampleLen bytes are sampled, however the data
+	// is written.
+	data := append(contextData(autoSampleLen), recordData(200000)...)
+	single := compressChunks(t, data, len(data))
+	chunked := compressChunks(t, data, 1000)
+	if !bytes.Equal(single, chunked) {
+		t.Fatalf("output depends on the size of the writes")
+	}
+	if lp := single[0] / 9 % 5; lp != 0 {
+		t.Errorf("header has LP %d; want 0", lp)
+	}
+}
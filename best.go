@@ -0,0 +1,75 @@
+package lzma
+
+// bestMatchers are the match algorithms tried by CompressBest.
+var bestMatchers = []MatchAlgorithm{HashTable4, BinaryTree}
+
+// CompressBest works like Compress but tries every match algorithm with a
+// set of candidate properties and keeps the smallest result. The
+// statistics describe the winning encoder; its properties and match
+// algorithm are recorded in the Properties and Matcher fields. The fields
+// Matcher, Properties and AutoProperties of cfg are ignored unless a Model
+// fixes the properties.
+//
+// The candidates are priced using the operations found by each match
+// algorithm. The operations of two algorithms are held at the same time and
+// every operation takes 24 bytes, so for poorly compressible input the
+// memory required is up to 48 times len(src) in addition to the encoders.
+// Use Compress with AutoProperties for large inputs.
+func CompressBest(dst, src []byte, cfg WriterConfig) ([]byte, Stats, error) {
+	cfg.SizeInHeader = true
+	cfg.Size = int64(len(src))
+	cfg.AutoProperties, cfg.PatchSize = false, false
+	cfg.fill()
+	if n := fitDictCap(len(src)); n < cfg.DictCap {
+		cfg.DictCap = n
+	}
+	if err := cfg.Verify(); err != nil {
+		return dst, Stats{}, err
+	}
+	props := propertyCandidates
+	if cfg.Model != nil {
+		props = []Properties{cfg.Model.Properties}
+	}
+
+	var (
+		best     []Op
+		bestCfg  WriterConfig
+		bestBits float64
+		n        int
+	)
+	for _, m := range bestMatchers {
+		c := cfg
+		c.Matcher = m
+		ops, err := Tokenize(src, c)
+		if err != nil {
+			return dst, Stats{}, err
+		}
+		for i := range props {
+			bits, err := opsCost(ops, props[i], c)
+			if err != nil {
+				return dst, Stats{}, err
+			}
+			if n == 0 || bits < bestBits {
+				best, bestBits = ops, bits
+				bestCfg = c
+				bestCfg.Properties = &props[i]
+			}
+			n++
+		}
+	}
+
+	bw := &appendByteWriter{p: dst}
+	w, err := bestCfg.NewWriter(bw)
+	if err != nil {
+		return dst, Stats{}, err
+	}
+	if _, err = w.WriteOps(best); err != nil {
+		return dst, Stats{}, err
+	}
+	if err = w.Close(); err != nil {
+		return dst, Stats{}, err
+	}
+	s := w.Stats()
+	s.Candidates = n
+	return bw.p, s, nil
+}
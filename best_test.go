@@ -0,0 +1,41 @@
+package lzma
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressBest(t *testing.T) {
+	inputs := [][]byte{nil, testData(1), testData(20000),
+		recordData(20000)}
+	for _, data := range inputs {
+		best, s, err := CompressBest([]byte("x"), data, WriterConfig{})
+		if err != nil {
+			t.Fatalf("CompressBest: %v", err)
+		}
+		if best[0] != 'x' {
+			t.Fatalf("CompressBest didn't append to dst")
+		}
+		best = best[1:]
+		got, err := Decompress(nil, best)
+		if err != nil {
+			t.Fatalf("n=%d: Decompress: %v", len(data), err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("n=%d: decompressed data differs", len(data))
+		}
+		plain, err := Compress(nil, data, WriterConfig{})
+		if err != nil {
+			t.Fatalf("Compress: %v", err)
+		}
+		if len(best) > len(plain) {
+			t.Errorf("n=%d: CompressBest gives %d bytes; Compress %d",
+				len(data), len(best), len(plain))
+		}
+		want := len(bestMatchers) * len(propertyCandidates)
+		if s.Candidates != want || s.OutputBytes != int64(len(best)) {
+			t.Errorf("n=%d: got %d candidates and %d output bytes",
+				len(data), s.Candidates, s.OutputBytes)
+		}
+	}
+}
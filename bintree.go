@@ -201,6 +201,39 @@ func (t *binTree) max(v uint32) uint32 {
 	}
 }
 
+func (t *binTree) min(v uint32) uint32 {
+	if v == null {
+		return null
+	}
+	for {
+		l := t.node[v].l
+		if l == null {
+			return v
+		}
+		v = l
+	}
+}
+
+func (t *binTree) succ(v uint32) uint32 {
+	if v == null {
+		return null
+	}
+	u := t.min(t.node[v].r)
+	if u != null {
+		return u
+	}
+	for {
+		p := t.node[v].p
+		if p == null {
+			return null
+		}
+		if t.node[p].l == v {
+			return p
+		}
+		v = p
+	}
+}
+
 func (t *binTree) pred(v uint32) uint32 {
 	if v == null {
 		return null
@@ -232,18 +265,22 @@ func xval(a []byte) uint32 {
 		fallthrough
 	case 2:
 		x |= uint32(a[1]) << 16
+		fallthrough
 	case 1:
 		x |= uint32(a[0]) << 24
 	}
 	return x
 }
 
+// distance returns the distance of the word stored in node v from the
+// dictionary head. The node before front stores the word ending at the
+// last byte written.
 func (t *binTree) distance(v uint32) int {
 	dist := int(t.front) - int(v)
 	if dist <= 0 {
 		dist += len(t.node)
 	}
-	return dist
+	return dist + wordLen - 1
 }
 
 type matchParams struct {
@@ -268,6 +305,9 @@ func (t *binTree) match(m match, distIter func() (int, bool), p matchParams) (ma
 			return m, checked, false
 		}
 		checked++
+		if dist > t.dict.DictLen() {
+			continue
+		}
 		if m.n > 0 {
 			i := buf.rear - dist + m.n - 1
 			if i < 0 {
@@ -342,7 +382,7 @@ func (t *binTree) NextOp(rep [4]uint32) operation {
 				return 0, false
 			}
 			dist := t.distance(u)
-			u, v := t.search(t.node[u].l, x)
+			u, v = t.search(t.node[u].l, x)
 			if u != v {
 				u = null
 			}
@@ -356,11 +396,8 @@ func (t *binTree) NextOp(rep [4]uint32) operation {
 		if v == null {
 			return 0, false
 		}
-		dist := t.distance(u)
-		u, v = t.search(t.node[u].l, x)
-		if u != v {
-			u = null
-		}
+		dist := t.distance(v)
+		v = t.succ(v)
 		return dist, true
 	}
 	m, checked, accepted = t.match(m, iterSucc, p)
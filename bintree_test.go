@@ -0,0 +1,54 @@
+package lzma
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBinaryTree(t *testing.T) {
+	cfg := WriterConfig{Matcher: BinaryTree}
+	type stream struct{ data, lzma []byte }
+	var streams []stream
+	for _, n := range []int{0, 1, 100, 70000} {
+		data := testData(n)
+		lzma := compressWith(t, cfg, data)
+		streams = append(streams, stream{data, lzma})
+		r, err := NewReader(bytes.NewReader(lzma))
+		if err != nil {
+			t.Fatalf("n=%d: NewReader: %v", n, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("n=%d: ReadAll: %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("n=%d: decoded data differs", n)
+		}
+	}
+
+	data := testData(100000)
+	lz := lzipCompress(t, LzipWriterConfig{Matcher: BinaryTree}, data)
+	got, err := lzipDecompress(ReaderConfig{}, lz)
+	if err != nil {
+		t.Fatalf("lzip: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("lzip: decoded data differs")
+	}
+
+	ops, err := Tokenize(data[:20000], cfg)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if got = writeOps(t, ops); !bytes.Equal(got, data[:20000]) {
+		t.Fatalf("WriteOps: decoded data differs")
+	}
+
+	// xzDecode skips the rest of the test if xz is not installed.
+	for _, s := range streams {
+		if got = xzDecode(t, s.lzma); !bytes.Equal(got, s.data) {
+			t.Fatalf("n=%d: xz output differs from input", len(s.data))
+		}
+	}
+}
@@ -84,6 +84,15 @@ func (b *buffer) Write(p []byte) (int, error) {
 	return n, err
 }
 
+func (b *buffer) WriteByte(c byte) error {
+	if b.Available() < 1 {
+		return ErrNoSpace
+	}
+	b.data[b.front] = c
+	b.front = b.addIndex(b.front, 1)
+	return nil
+}
+
 func prefixLen(a, b []byte) int {
 	if len(a) > len(b) {
 		a, b = b, a
@@ -100,7 +109,7 @@ func (b *buffer) matchLen(distance int, p []byte) int {
 	var n int
 	i := b.rear - distance
 	if i < 0 {
-		if n = prefixLen(p, b.data[len(b.data)+1:]); n < -i {
+		if n = prefixLen(p, b.data[len(b.data)+i:]); n < -i {
 			return n
 		}
 		p = p[n:]
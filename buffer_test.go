@@ -0,0 +1,26 @@
+package lzma
+
+import "testing"
+
+func TestBufferMatchLenWrap(t *testing.T) {
+	b := newBuffer(7)
+	b.data = []byte("efghabcd")
+	b.rear = 2
+	tests := []struct {
+		distance int
+		p        string
+		n        int
+	}{
+		{4, "cdefX", 4},
+		{4, "cdX", 2},
+		{4, "cX", 1},
+		{2, "efgh", 4},
+		{1, "fX", 1},
+	}
+	for _, tc := range tests {
+		if n := b.matchLen(tc.distance, []byte(tc.p)); n != tc.n {
+			t.Errorf("matchLen(%d, %q) = %d; want %d",
+				tc.distance, tc.p, n, tc.n)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package lzma
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"mylzma/internal/hash"
+)
+
+// checkpointVersion identifies the layout of writer checkpoints.
+const checkpointVersion = 1
+
+// writerCheckpoint stores the complete state of a Writer. Encoder is nil
+// if the writer still collects the sample for AutoProperties.
+type writerCheckpoint struct {
+	Version          int
+	Properties       Properties
+	DictCap          int
+	BufSize          int
+	Matcher          MatchAlgorithm
+	Size             int64
+	EOSMarker        bool
+	PatchSize        bool
+	Start            int64
+	AutoProperties   bool
+	EntropyThreshold float64
+	Sample           []byte
+	Entries          []Entry
+	Output           int64
+	Encoder          *encoderCheckpoint
+}
+
+// encoderCheckpoint stores the state of the encoder including the
+// dictionary and the match finder.
+type encoderCheckpoint struct {
+	Start    int64
+	Stats    Stats
+	BlockEnd int64
+	SkipEnd  int64
+
+	State int
+	Rep   [4]uint32
+	Probs []uint16
+
+	Low      uint64
+	Range    uint32
+	Cache    byte
+	CacheLen int64
+	Shifts   int64
+	Written  int64
+
+	Data        []byte
+	Front, Rear int
+	Head        int64
+
+	// hash table
+	Table     []int64
+	Deltas    []uint32
+	HashFront int
+	HashOff   int64
+
+	// binary tree; every node is stored as x, p, l and r
+	Nodes     []uint32
+	TreeOff   int64
+	TreeFront uint32
+	Root      uint32
+	X         uint32
+}
+
+// checkpoint copies the state of the encoder.
+func (e *encoder) checkpoint() *encoderCheckpoint {
+	d := e.dict
+	c := &encoderCheckpoint{
+		Start:    e.start,
+		Stats:    e.stats,
+		BlockEnd: e.blockEnd,
+		SkipEnd:  e.skipEnd,
+		State:    int(e.state.state),
+		Rep:      e.state.rep,
+		Low:      e.re.low,
+		Range:    e.re.nrange,
+		Cache:    e.re.cache,
+		CacheLen: e.re.cacheLen,
+		Shifts:   e.re.shifts,
+		Written:  e.re.written,
+		Data:     d.buf.data,
+		Front:    d.buf.front,
+		Rear:     d.buf.rear,
+		Head:     d.head,
+		Probs:    flatProbs(e.state),
+	}
+	switch m := d.m.(type) {
+	case *hashTable:
+		c.Table, c.Deltas = m.t, m.data
+		c.HashFront, c.HashOff = m.front, m.hoff
+	case *binTree:
+		c.Nodes = make([]uint32, 0, 4*len(m.node))
+		for _, v := range m.node {
+			c.Nodes = append(c.Nodes, v.x, v.p, v.l, v.r)
+		}
+		c.TreeOff, c.TreeFront, c.Root, c.X = m.hoff, m.front, m.root, m.x
+	}
+	return c
+}
+
+var errCheckpoint = errors.New("lzma: checkpoint doesn't match the configuration")
+
+// flatProbs returns all probabilities of the state in one slice.
+func flatProbs(s *state) []uint16 {
+	var v []uint16
+	for _, p := range s.probSlices() {
+		for _, x := range p {
+			v = append(v, uint16(x))
+		}
+	}
+	return v
+}
+
+// loadProbs sets the probabilities of the state to the values provided by
+// flatProbs.
+func loadProbs(s *state, v []uint16) error {
+	for _, p := range s.probSlices() {
+		if len(p) > len(v) {
+			return errCheckpoint
+		}
+		for i := range p {
+			p[i] = prob(v[i])
+		}
+		v = v[len(p):]
+	}
+	if len(v) != 0 {
+		return errCheckpoint
+	}
+	return nil
+}
+
+// restore replaces the state of the freshly created encoder by the
+// checkpoint.
+func (e *encoder) restore(c *encoderCheckpoint) error {
+	e.start = c.Start
+	e.stats = c.Stats
+	e.blockEnd, e.skipEnd = c.BlockEnd, c.SkipEnd
+
+	if c.State < 0 || c.State >= states {
+		return errCheckpoint
+	}
+	e.state.state = uint32(c.State)
+	e.state.rep = c.Rep
+	if err := loadProbs(e.state, c.Probs); err != nil {
+		return err
+	}
+
+	re := e.re
+	re.low, re.nrange, re.cache = c.Low, c.Range, c.Cache
+	re.cacheLen, re.shifts, re.written = c.CacheLen, c.Shifts, c.Written
+	re.lbw.N -= c.Written
+
+	d := e.dict
+	if len(c.Data) != len(d.buf.data) {
+		return errCheckpoint
+	}
+	copy(d.buf.data, c.Data)
+	d.buf.front, d.buf.rear, d.head = c.Front, c.Rear, c.Head
+
+	switch m := d.m.(type) {
+	case *hashTable:
+		if len(c.Table) != len(m.t) || len(c.Deltas) != len(m.data) {
+			return errCheckpoint
+		}
+		copy(m.t, c.Table)
+		copy(m.data, c.Deltas)
+		m.front, m.hoff = c.HashFront, c.HashOff
+		// The rolling hash depends only on the last bytes written.
+		m.wr = newRoller(m.wordLen)
+		replayRoller(m.wr, d, m.wordLen)
+	case *binTree:
+		if len(c.Nodes) != 4*len(m.node) {
+			return errCheckpoint
+		}
+		for i := range m.node {
+			v := c.Nodes[4*i:]
+			m.node[i] = node{x: v[0], p: v[1], l: v[2], r: v[3]}
+		}
+		m.hoff, m.front, m.root, m.x = c.TreeOff, c.TreeFront, c.Root, c.X
+	}
+	return nil
+}
+
+// replayRoller feeds the last n bytes written into the match finder of d
+// into the roller r.
+func replayRoller(r hash.Roller, d *encoderDict, n int) {
+	if d.head < int64(n) {
+		n = int(d.head)
+	}
+	for i := n; i > 0; i-- {
+		r.RollByte(d.ByteAt(i))
+	}
+}
+
+// Checkpoint flushes the output and writes the complete state of the
+// writer to cp. It returns the number of bytes written to the output
+// since the writer has been created. The writer may continue afterwards.
+// ResumeWriter restores the state in another process.
+func (w *Writer) Checkpoint(cp io.Writer) (output int64, err error) {
+	if w.buf != nil {
+		if err = w.buf.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	c := writerCheckpoint{
+		Version:          checkpointVersion,
+		Properties:       w.h.properties,
+		DictCap:          w.h.dictCap,
+		BufSize:          w.cfg.BufSize,
+		Matcher:          w.cfg.Matcher,
+		Size:             w.h.size,
+		EOSMarker:        w.cfg.EOSMarker,
+		PatchSize:        w.ws != nil,
+		Start:            w.start,
+		AutoProperties:   w.e == nil,
+		EntropyThreshold: w.cfg.EntropyThreshold,
+		Sample:           w.sample,
+		Entries:          w.entries,
+	}
+	if w.e != nil {
+		c.Output = HeaderLen + w.e.re.written
+		c.Encoder = w.e.checkpoint()
+	}
+	if err = gob.NewEncoder(cp).Encode(&c); err != nil {
+		return 0, err
+	}
+	return c.Output, nil
+}
+
+// ResumeWriter creates a writer from the checkpoint read from cp. The
+// output must contain exactly the bytes written up to the checkpoint, as
+// reported by Checkpoint, and lzma must continue at its end. The output
+// of the resumed writer is identical to the output of the original writer
+// after the checkpoint. Trace functions are not restored.
+func ResumeWriter(lzma io.Writer, cp io.Reader) (*Writer, error) {
+	var c writerCheckpoint
+	if err := gob.NewDecoder(cp).Decode(&c); err != nil {
+		return nil, fmt.Errorf("lzma: can't read checkpoint: %w", err)
+	}
+	if c.Version != checkpointVersion {
+		return nil, fmt.Errorf("lzma: unsupported checkpoint version %d",
+			c.Version)
+	}
+	cfg := WriterConfig{
+		Properties:       &c.Properties,
+		DictCap:          c.DictCap,
+		BufSize:          c.BufSize,
+		Matcher:          c.Matcher,
+		SizeInHeader:     c.Size >= 0,
+		EOSMarker:        c.EOSMarker,
+		PatchSize:        c.PatchSize,
+		AutoProperties:   c.AutoProperties,
+		EntropyThreshold: c.EntropyThreshold,
+	}
+	if c.Size >= 0 {
+		cfg.Size = c.Size
+	}
+	if err := cfg.Verify(); err != nil {
+		return nil, err
+	}
+	w := &Writer{h: cfg.header(), cfg: cfg, sample: c.Sample,
+		entries: c.Entries}
+	if c.PatchSize {
+		var ok bool
+		if w.ws, ok = lzma.(io.WriteSeeker); !ok {
+			return nil, &ConfigError{"PatchSize",
+				"PatchSize requires an io.WriteSeeker"}
+		}
+		w.start = c.Start
+	}
+	var ok bool
+	if w.bw, ok = lzma.(io.ByteWriter); !ok {
+		w.buf = bufio.NewWriter(lzma)
+		w.bw = w.buf
+	}
+	if c.Encoder == nil {
+		return w, nil
+	}
+	var err error
+	if w.e, err = cfg.newEncoder(w.bw); err != nil {
+		return nil, err
+	}
+	if err = w.e.restore(c.Encoder); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// readerCheckpoint stores the state of a Reader in the middle of an LZMA
+// stream.
+type readerCheckpoint struct {
+	Version    int
+	Properties Properties
+	DictCap    int
+	Size       int64
+
+	Start     int64
+	EOS       bool
+	EOSMarker bool
+	In, Out   int64
+
+	State int
+	Rep   [4]uint32
+	Probs []uint16
+
+	Code  uint32
+	Range uint32
+	Read  int64
+
+	Capacity    int
+	Data        []byte
+	Front, Rear int
+	Head        int64
+}
+
+// Checkpoint writes the state of the reader to cp. It returns the offset
+// of the next compressed byte the reader needs; ResumeReader expects the
+// input to continue there. Decompressed data that has not been read yet
+// is part of the checkpoint.
+func (r *Reader) Checkpoint(cp io.Writer) (offset int64, err error) {
+	if r.err != nil && r.err != io.EOF {
+		return 0, r.err
+	}
+	d := r.d
+	if d.err != nil {
+		return 0, d.err
+	}
+	c := readerCheckpoint{
+		Version:    checkpointVersion,
+		Properties: r.h.properties,
+		DictCap:    r.h.dictCap,
+		Size:       r.h.size,
+		Start:      d.start,
+		EOS:        d.eos,
+		EOSMarker:  d.eosMarker,
+		In:         d.in,
+		Out:        d.out,
+		State:      int(d.state.state),
+		Rep:        d.state.rep,
+		Code:       d.rd.code,
+		Range:      d.rd.nrange,
+		Read:       d.rd.read,
+		Capacity:   d.dict.capacity,
+		Data:       d.dict.buf.data,
+		Front:      d.dict.buf.front,
+		Rear:       d.dict.buf.rear,
+		Head:       d.dict.head,
+		Probs:      flatProbs(d.state),
+	}
+	if err = gob.NewEncoder(cp).Encode(&c); err != nil {
+		return 0, err
+	}
+	return d.in + d.rd.read, nil
+}
+
+// ResumeReader creates a reader from the checkpoint read from cp. The
+// input lzma must start at the offset returned by Checkpoint. The
+// configuration applies to the resumed reader; its limits count the data
+// from the start of the original input.
+func (c ReaderConfig) ResumeReader(lzma io.Reader, cp io.Reader) (*Reader, error) {
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	var k readerCheckpoint
+	if err := gob.NewDecoder(cp).Decode(&k); err != nil {
+		return nil, fmt.Errorf("lzma: can't read checkpoint: %w", err)
+	}
+	if k.Version != checkpointVersion {
+		return nil, fmt.Errorf("lzma: unsupported checkpoint version %d",
+			k.Version)
+	}
+	if err := k.Properties.verify(); err != nil {
+		return nil, err
+	}
+	if k.State < 0 || k.State >= states || len(k.Data) != k.Capacity+1 ||
+		k.Front < 0 || k.Front >= len(k.Data) ||
+		k.Rear < 0 || k.Rear >= len(k.Data) {
+		return nil, errCheckpoint
+	}
+	if err := c.checkDictCap(k.DictCap); err != nil {
+		return nil, err
+	}
+	s := newState(k.Properties)
+	s.state, s.rep = uint32(k.State), k.Rep
+	if err := loadProbs(s, k.Probs); err != nil {
+		return nil, err
+	}
+	dict := &decoderDict{
+		buf:      buffer{data: k.Data, front: k.Front, rear: k.Rear},
+		head:     k.Head,
+		capacity: k.Capacity,
+	}
+	r := &Reader{
+		cfg: c,
+		br:  byteReader(lzma),
+		h: header{properties: k.Properties, dictCap: k.DictCap,
+			size: k.Size},
+	}
+	r.d = &decoder{
+		dict:      dict,
+		state:     s,
+		rd:        &rangeDecoder{br: r.br, nrange: k.Range, code: k.Code, read: k.Read},
+		start:     k.Start,
+		size:      k.Size,
+		eos:       k.EOS,
+		eosMarker: k.EOSMarker,
+		in:        k.In,
+		out:       k.Out,
+		trace:     c.Trace,
+		lim:       c.newLimits(),
+		salvage:   c.Salvage,
+	}
+	return r, nil
+}
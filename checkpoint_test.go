@@ -0,0 +1,94 @@
+package lzma
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriterCheckpoint(t *testing.T) {
+	data := testData(40000)
+	// A small dictionary keeps the checkpoints small and is filled
+	// before the checkpoint.
+	const dictCap = MinDictCap
+	configs := []WriterConfig{
+		{DictCap: dictCap, Matcher: HashTable4},
+		{DictCap: dictCap, Matcher: BinaryTree},
+		{DictCap: dictCap, EntropyThreshold: 7.5},
+		{DictCap: dictCap, SizeInHeader: true, Size: int64(len(data))},
+	}
+	for i, cfg := range configs {
+		for _, cut := range []int{0, 1000, 30000} {
+			var out bytes.Buffer
+			w, err := cfg.NewWriter(&out)
+			if err != nil {
+				t.Fatalf("NewWriter: %v", err)
+			}
+			if _, err = w.Write(data[:cut]); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			var cp bytes.Buffer
+			n, err := w.Checkpoint(&cp)
+			if err != nil {
+				t.Fatalf("Checkpoint: %v", err)
+			}
+			if n != int64(out.Len()) {
+				t.Fatalf("Checkpoint reports %d bytes; output has %d",
+					n, out.Len())
+			}
+			resumed := bytes.NewBuffer(append([]byte(nil), out.Bytes()...))
+			if _, err = w.Write(data[cut:]); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			w2, err := ResumeWriter(resumed, &cp)
+			if err != nil {
+				t.Fatalf("ResumeWriter: %v", err)
+			}
+			if _, err = w2.Write(data[cut:]); err != nil {
+				t.Fatalf("Write after resume: %v", err)
+			}
+			if err = w2.Close(); err != nil {
+				t.Fatalf("Close after resume: %v", err)
+			}
+			if !bytes.Equal(resumed.Bytes(), out.Bytes()) {
+				t.Fatalf("config %d cut %d: resumed output differs",
+					i, cut)
+			}
+		}
+	}
+}
+
+func TestReaderCheckpoint(t *testing.T) {
+	data := testData(100000)
+	lzma := compressWith(t, WriterConfig{DictCap: MinDictCap}, data)
+	for _, cut := range []int{0, 1, 5000, 99999, 100000} {
+		r, err := NewReader(bytes.NewReader(lzma))
+		if err != nil {
+			t.Fatalf("NewReader: %v", err)
+		}
+		head := make([]byte, cut)
+		if _, err = io.ReadFull(r, head); err != nil {
+			t.Fatalf("ReadFull: %v", err)
+		}
+		var cp bytes.Buffer
+		off, err := r.Checkpoint(&cp)
+		if err != nil {
+			t.Fatalf("Checkpoint: %v", err)
+		}
+		r2, err := ReaderConfig{}.ResumeReader(bytes.NewReader(lzma[off:]), &cp)
+		if err != nil {
+			t.Fatalf("ResumeReader: %v", err)
+		}
+		tail, err := io.ReadAll(r2)
+		if err != nil {
+			t.Fatalf("cut %d: reading after resume: %v", cut, err)
+		}
+		if !bytes.Equal(append(head, tail...), data) {
+			t.Fatalf("cut %d: resumed reader returns wrong data", cut)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Command lzmatool provides tools to inspect LZMA files.
+//
+// Usage:
+//
+//	lzmatool disasm [file]
+//	lzmatool test [file]
+//	lzmatool analyze [file]
+//
+// The disasm command prints every operation of an .lzma file. The test
+// command decodes an .lzma or .lz file, checks its integrity and prints a
+// summary. The analyze command reports the entropy, the match coverage and
+// a suggested filter for every 64 KiB of uncompressed input. If no file is
+// given, standard input is read.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	lzma "mylzma"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lzmatool disasm|test|analyze [file]")
+	os.Exit(2)
+}
+
+// input opens the file given by args or returns standard input.
+func input(args []string) (io.ReadCloser, error) {
+	switch len(args) {
+	case 0:
+		return io.NopCloser(os.Stdin), nil
+	case 1:
+		return os.Open(args[0])
+	}
+	usage()
+	return nil, nil
+}
+
+func disasm(args []string) error {
+	r, err := input(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return lzma.Disassemble(r, os.Stdout)
+}
+
+func test(args []string) error {
+	r, err := input(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	info, err := lzma.Verify(r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("format %s, compressed %d, uncompressed %d, "+
+		"ratio %.3f, check %s\n", info.Format, info.Compressed,
+		info.Uncompressed, info.Ratio(), info.Check)
+	return nil
+}
+
+func analyze(args []string) error {
+	r, err := input(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	windows, err := lzma.Analyze(r, lzma.WriterConfig{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("#    offset entropy   lit")
+	for i, d := range lzma.DistRanges {
+		if i == len(lzma.DistRanges)-1 {
+			fmt.Printf(" %7s", ">"+sizeString(lzma.DistRanges[i-1]))
+			break
+		}
+		fmt.Printf(" %7s", "<="+sizeString(d))
+	}
+	fmt.Println("   rep repfreq filter")
+	for _, w := range windows {
+		fmt.Printf("%11d %7.3f %5.1f", w.Offset, w.Entropy,
+			100*w.LiteralCoverage)
+		for _, m := range w.MatchCoverage {
+			fmt.Printf(" %7.1f", 100*m)
+		}
+		fmt.Printf(" %5.1f %7.3f %s\n", 100*w.RepCoverage,
+			w.RepFrequency, w.Filter)
+	}
+	return nil
+}
+
+// sizeString formats n using binary prefixes.
+func sizeString(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%dG", n>>30)
+	case n >= 1<<20:
+		return fmt.Sprintf("%dM", n>>20)
+	case n >= 1<<10:
+		return fmt.Sprintf("%dK", n>>10)
+	}
+	return fmt.Sprint(n)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "disasm":
+		err = disasm(os.Args[2:])
+	case "test":
+		err = test(os.Args[2:])
+	case "analyze":
+		err = analyze(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lzmatool:", err)
+		os.Exit(1)
+	}
+}
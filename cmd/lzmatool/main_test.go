@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lzma "mylzma"
+)
+
+// TestMain runs the command instead of the tests if LZMATOOL_MAIN is set,
+// which allows the tests to execute the test binary as lzmatool.
+func TestMain(m *testing.M) {
+	if os.Getenv("LZMATOOL_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run executes lzmatool with the given arguments and returns its output.
+func run(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "LZMATOOL_MAIN=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("lzmatool %s: %v: %s", strings.Join(args, " "), err,
+			stderr.Bytes())
+	}
+	return string(out)
+}
+
+// writeFile writes data to a file in a temporary directory and returns
+// its path.
+func writeFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDisasm(t *testing.T) {
+	data := bytes.Repeat([]byte("lzmatool disasm "), 100)
+	var ops []lzma.TraceOp
+	var buf bytes.Buffer
+	cfg := lzma.WriterConfig{DictCap: 1 << 16, Trace: func(op lzma.TraceOp) {
+		ops = append(ops, op)
+	}}
+	w, err := cfg.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out := run(t, "disasm", writeFile(t, buf.Bytes()))
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != len(ops)+2 {
+		t.Fatalf("got %d lines; want %d", len(lines), len(ops)+2)
+	}
+	if want := "# lc 3 lp 0 pb 2 dictCap 65536 size -1"; lines[0] != want {
+		t.Errorf("first line %q; want %q", lines[0], want)
+	}
+	for i, op := range ops {
+		f := strings.Fields(lines[i+2])
+		if len(f) < 3 || f[1] != fmt.Sprint(op.StateBefore) ||
+			f[2] != op.Kind.String() {
+			t.Fatalf("line %q doesn't match %v", lines[i+2], op)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	// four interleaved byte ramps followed by a partial window of text
+	data := make([]byte, lzma.AnalysisWindow)
+	for i := range data {
+		data[i] = byte(i/4 + 61*(i%4))
+	}
+	data = append(data, bytes.Repeat([]byte("lzmatool analyze\n"), 100)...)
+
+	out := run(t, "analyze", writeFile(t, data))
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "#") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	for i, want := range []string{"delta:4", "none"} {
+		f := strings.Fields(lines[i+1])
+		if len(f) != 11 {
+			t.Fatalf("line %q has %d fields", lines[i+1], len(f))
+		}
+		if f[0] != fmt.Sprint(i*lzma.AnalysisWindow) || f[10] != want {
+			t.Errorf("line %q; want offset %d and filter %s",
+				lines[i+1], i*lzma.AnalysisWindow, want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package lzma
+
+import (
+	"bytes"
+	"io"
+)
+
+// sliceByteWriter writes bytes into a fixed byte slice.
+type sliceByteWriter struct {
+	p []byte
+	n int
+}
+
+func (w *sliceByteWriter) WriteByte(c byte) error {
+	if w.n >= len(w.p) {
+		return ErrNoSpace
+	}
+	w.p[w.n] = c
+	w.n++
+	return nil
+}
+
+// CompressInto compresses as much of src as fits into dst as a complete
+// LZMA stream. The header records the number of consumed bytes, so an
+// EOS marker is only written if cfg.EOSMarker is set. The fields Size and
+// SizeInHeader of cfg are ignored. The dictionary capacity is reduced if
+// src is smaller. An error is returned if dst cannot hold even an empty
+// stream.
+func CompressInto(dst, src []byte, cfg WriterConfig) (consumed, written int, err error) {
+	cfg.SizeInHeader = true
+	cfg.Size = 0
+	cfg.fill()
+	if n := fitDictCap(len(src)); n < cfg.DictCap {
+		cfg.DictCap = n
+	}
+	if err = cfg.Verify(); err != nil {
+		return 0, 0, err
+	}
+	if err = cfg.autoProperties(src); err != nil {
+		return 0, 0, err
+	}
+	if len(dst) < HeaderLen {
+		return 0, 0, ErrNoSpace
+	}
+	h := cfg.header()
+	bw := &sliceByteWriter{p: dst, n: HeaderLen}
+	lbw := &LimitedByteWriter{BW: bw, N: int64(len(dst) - HeaderLen)}
+	e, err := cfg.newEncoder(lbw)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err = e.Write(src); err != nil && err != ErrLimit {
+		return 0, 0, err
+	}
+	if err = e.Close(); err != nil {
+		if err == ErrLimit {
+			err = ErrNoSpace
+		}
+		return 0, 0, err
+	}
+
+	h.size = e.Compressed()
+	data, err := h.marshalBinary()
+	if err != nil {
+		return 0, 0, err
+	}
+	copy(dst, data)
+	return int(h.size), bw.n, nil
+}
+
+// appendByteWriter appends all bytes to a byte slice.
+type appendByteWriter struct {
+	p []byte
+}
+
+func (w *appendByteWriter) WriteByte(c byte) error {
+	w.p = append(w.p, c)
+	return nil
+}
+
+func (w *appendByteWriter) Write(p []byte) (int, error) {
+	w.p = append(w.p, p...)
+	return len(p), nil
+}
+
+// fitDictCap returns the smallest dictionary capacity of the form 2^n or
+// 2^n+2^(n-1) that holds n bytes. Other values are rejected by some
+// decoders.
+func fitDictCap(n int) int {
+	c := MinDictCap
+	for c < n {
+		if c+c/2 >= n {
+			return c + c/2
+		}
+		c *= 2
+	}
+	return c
+}
+
+// CompressBound returns an estimate of the maximum output size of Compress
+// for n input bytes including the header. It follows the recommendation of
+// the LZMA SDK and holds for random data, but it is not a guaranteed bound:
+// LZMA has no uncompressed mode and the cost of an operation depends on the
+// adaptive model, so crafted input may expand further. Callers of
+// CompressInto must be prepared to receive ErrNoSpace or a partial result.
+func CompressBound(n int) int {
+	return HeaderLen + n + n/3 + 128
+}
+
+// Compress appends the LZMA stream for src to dst and returns the extended
+// slice. The header records the size of src, so an EOS marker is only
+// written if cfg.EOSMarker is set. The dictionary capacity is reduced if src
+// is smaller. The fields Size and SizeInHeader of cfg are ignored.
+func Compress(dst, src []byte, cfg WriterConfig) ([]byte, error) {
+	cfg.SizeInHeader = true
+	cfg.Size = int64(len(src))
+	cfg.fill()
+	if n := fitDictCap(len(src)); n < cfg.DictCap {
+		cfg.DictCap = n
+	}
+	if err := cfg.Verify(); err != nil {
+		return dst, err
+	}
+	if err := cfg.autoProperties(src); err != nil {
+		return dst, err
+	}
+	h := cfg.header()
+	data, err := h.marshalBinary()
+	if err != nil {
+		return dst, err
+	}
+	if n := CompressBound(len(src)); cap(dst)-len(dst) < n {
+		p := make([]byte, len(dst), len(dst)+n)
+		copy(p, dst)
+		dst = p
+	}
+	bw := &appendByteWriter{p: append(dst, data...)}
+	e, err := cfg.newEncoder(bw)
+	if err != nil {
+		return dst, err
+	}
+	if _, err = e.Write(src); err != nil {
+		return dst, err
+	}
+	if err = e.Close(); err != nil {
+		return dst, err
+	}
+	return bw.p, nil
+}
+
+// maxPreallocRatio limits the output size that Decompress allocates in
+// advance relative to the input size.
+const maxPreallocRatio = 1024
+
+// Decompress appends the data decompressed from the LZMA stream src to dst
+// and returns the extended slice. If the header provides the size, the
+// output must have exactly that size and is decoded directly into dst
+// without an intermediate buffer, as long as the size is plausible for the
+// input size. Concatenated streams are decoded as for the Reader.
+func Decompress(dst, src []byte) ([]byte, error) {
+	return ReaderConfig{}.Decompress(dst, src)
+}
+
+// Decompress works like the function Decompress but enforces the limits
+// of the configuration. The field DictCap is ignored. If Salvage is set,
+// the data decoded before an error is appended to dst. Memory is only
+// allocated for as much output as MaxSize and MaxRatio permit.
+func (c ReaderConfig) Decompress(dst, src []byte) ([]byte, error) {
+	if err := c.Verify(); err != nil {
+		return dst, err
+	}
+	var in, out int64
+	for {
+		n := len(dst)
+		var k int64
+		var err error
+		dst, k, err = c.decompressStream(dst, src, in, out)
+		if err != nil {
+			return dst, err
+		}
+		in += k
+		out += int64(len(dst) - n)
+		if c.SingleStream || in == int64(len(src)) {
+			return dst, nil
+		}
+	}
+}
+
+// streamHeader reads and checks the header of the stream at src[in:],
+// which follows out decompressed bytes.
+func (c *ReaderConfig) streamHeader(src []byte, in, out int64) (h header, err error) {
+	if int64(len(src))-in < HeaderLen {
+		return h, &CorruptInputError{Offset: int64(len(src)), Pos: out,
+			Reason: ReasonTruncated, Err: io.ErrUnexpectedEOF}
+	}
+	if err = h.unmarshalBinary(src[in : in+HeaderLen]); err != nil {
+		if e, ok := err.(*CorruptInputError); ok {
+			e.Offset += in
+			e.Pos = out
+		}
+		return h, err
+	}
+	if err = c.checkDictCap(h.dictCap); err != nil {
+		return h, err
+	}
+	if h.size >= 0 {
+		if err = c.checkSize(out + h.size); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// decompressStream appends the data of the stream at src[in:], which
+// follows out decompressed bytes, to dst. It returns the extended slice
+// and the length of the stream.
+func (c *ReaderConfig) decompressStream(dst, src []byte, in, out int64) ([]byte, int64, error) {
+	h, err := c.streamHeader(src, in, out)
+	if err != nil {
+		if in > 0 {
+			err = trailingData(err, in, out)
+		}
+		return dst, 0, err
+	}
+	state, err := c.newState(h.properties)
+	if err != nil {
+		return dst, 0, err
+	}
+	// Neither the preallocated output nor the dictionary needs to be
+	// larger than the output permitted by the limits.
+	limit := c.outputLimit(len(src))
+	if limit >= 0 {
+		limit -= out
+	}
+	prealloc := maxPreallocRatio * int64(len(src))
+	if limit >= 0 && limit < prealloc {
+		prealloc = limit
+	}
+	n := len(dst)
+	sized := h.size >= 0 && h.size <= prealloc
+	var dict *decoderDict
+	if sized {
+		// The decoder requires space for a maximum length match.
+		k := int(h.size) + maxMatchLen + 1
+		if cap(dst)-n < k {
+			p := make([]byte, n, n+k)
+			copy(p, dst)
+			dst = p
+		}
+		dict = &decoderDict{
+			buf:      buffer{data: dst[n : n+k]},
+			capacity: k - 1,
+		}
+	} else {
+		if limit >= 0 && limit < int64(h.dictCap) {
+			h.dictCap = int(limit)
+		}
+		if h.dictCap < MinDictCap {
+			h.dictCap = MinDictCap
+		}
+		if dict, err = newDecoderDict(h.dictCap); err != nil {
+			return dst, 0, err
+		}
+	}
+	br := bytes.NewReader(src[in+HeaderLen:])
+	d, err := newDecoder(br, state, dict, h.size, in+HeaderLen, out)
+	if err != nil {
+		if in > 0 {
+			err = trailingData(err, in, out)
+		}
+		return dst, 0, err
+	}
+	d.lim = c.newLimits()
+	d.salvage = c.Salvage
+	if sized {
+		// The data is decoded directly into the capacity of dst.
+		switch err = d.decompress(); err {
+		case io.EOF:
+			err = nil
+		case nil:
+			err = d.corrupt(ReasonSizeMismatch, nil)
+		}
+		if err == nil || c.Salvage {
+			dst = dst[:n+int(d.Decompressed())]
+		}
+		return dst, HeaderLen + d.rd.read, err
+	}
+	for {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+		k, err := d.Read(dst[len(dst):cap(dst)])
+		dst = dst[:len(dst)+k]
+		if err == io.EOF {
+			return dst, HeaderLen + d.rd.read, nil
+		}
+		if err != nil {
+			return dst, HeaderLen + d.rd.read, err
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package lzma
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	for _, n := range []int{0, 1, 1000, 100000} {
+		data := testData(n)
+		for _, m := range []MatchAlgorithm{HashTable4, BinaryTree} {
+			lzma, err := Compress(nil, data, WriterConfig{Matcher: m})
+			if err != nil {
+				t.Fatalf("Compress: %v", err)
+			}
+			if len(lzma) > CompressBound(n) {
+				t.Errorf("n=%d: compressed size %d exceeds bound %d",
+					n, len(lzma), CompressBound(n))
+			}
+			got, err := Decompress([]byte("x"), lzma)
+			if err != nil {
+				t.Fatalf("%v n=%d: Decompress: %v", m, n, err)
+			}
+			if !bytes.Equal(got[1:], data) || got[0] != 'x' {
+				t.Fatalf("%v n=%d: decompressed data differs", m, n)
+			}
+		}
+	}
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	data := testData(50000)
+	sized, err := Compress(nil, data, WriterConfig{})
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	eos := compressWith(t, WriterConfig{}, data)
+	for _, lzma := range [][]byte{sized, eos} {
+		cfg := ReaderConfig{MaxSize: int64(len(data))}
+		if _, err = cfg.Decompress(nil, lzma); err != nil {
+			t.Fatalf("Decompress at limit: %v", err)
+		}
+		cfg.MaxSize--
+		_, err = cfg.Decompress(nil, lzma)
+		var e *SizeLimitError
+		if !errors.As(err, &e) {
+			t.Fatalf("Decompress got error %v; want SizeLimitError", err)
+		}
+	}
+}
+
+func TestDecompressMaxRatioAlloc(t *testing.T) {
+	data := make([]byte, 10<<20)
+	for i := 0; i < len(data); i += 1024 {
+		data[i] = byte(i * 7919 >> 10)
+	}
+	lzma, err := Compress(nil, data, WriterConfig{})
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	cfg := ReaderConfig{MaxRatio: 2}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = cfg.Decompress(nil, lzma)
+	runtime.ReadMemStats(&after)
+	var e *RatioLimitError
+	if !errors.As(err, &e) {
+		t.Fatalf("Decompress got error %v; want RatioLimitError", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Decompress of %d bytes allocated %d bytes", len(lzma), n)
+	}
+}
+
+func TestCompressInto(t *testing.T) {
+	data := testData(20000)
+	dst := make([]byte, 1024)
+	var frames int
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for src := data; len(src) > 0; {
+		consumed, written, err := CompressInto(dst, src, WriterConfig{})
+		if err != nil {
+			t.Fatalf("CompressInto: %v", err)
+		}
+		if consumed == 0 || written > len(dst) {
+			t.Fatalf("CompressInto consumed %d bytes and wrote %d",
+				consumed, written)
+		}
+		got, err := Decompress(nil, dst[:written])
+		if err != nil {
+			t.Fatalf("Decompress: %v", err)
+		}
+		if !bytes.Equal(got, src[:consumed]) {
+			t.Fatalf("decompressed frame differs")
+		}
+		src = src[consumed:]
+		frames++
+	}
+	runtime.ReadMemStats(&after)
+	if frames < 2 {
+		t.Fatalf("data fits into a single frame")
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > uint64(frames)<<20 {
+		t.Errorf("compressing %d frames allocated %d bytes",
+			frames, n)
+	}
+	if _, _, err := CompressInto(dst[:HeaderLen-1], data, WriterConfig{}); err != ErrNoSpace {
+		t.Errorf("CompressInto with short dst: %v; want ErrNoSpace", err)
+	}
+}
+
+func TestCompressBoundRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for _, n := range []int{1, 1000, 100000} {
+		data := make([]byte, n)
+		rnd.Read(data)
+		lzma, err := Compress(nil, data, WriterConfig{})
+		if err != nil {
+			t.Fatalf("Compress: %v", err)
+		}
+		if len(lzma) > CompressBound(n) {
+			t.Errorf("n=%d: compressed size %d exceeds bound %d",
+				n, len(lzma), CompressBound(n))
+		}
+		dst := make([]byte, CompressBound(n))
+		consumed, _, err := CompressInto(dst, data, WriterConfig{})
+		if err != nil {
+			t.Fatalf("CompressInto: %v", err)
+		}
+		if consumed != n {
+			t.Errorf("n=%d: CompressInto consumed %d bytes", n, consumed)
+		}
+	}
+}
@@ -1,7 +1,328 @@
 package lzma
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"io"
+)
 
 var (
 	errSize = errors.New("lzma: wrong uncompressed data size")
+	errEOS  = errors.New("lzma: EOS marker")
 )
+
+// eosDist is the distance value of the end-of-stream marker.
+const eosDist = maxDistance - minDistance
+
+type decoder struct {
+	dict      *decoderDict
+	state     *state
+	rd        *rangeDecoder
+	start     int64
+	size      int64
+	eos       bool
+	eosMarker bool
+	// kind of the last operation read
+	kind  OpKind
+	trace func(TraceOp)
+	lim   *limits
+	err   error
+	// salvage lets Read return the decoded bytes before reporting err
+	salvage bool
+	// compressed and decompressed bytes preceding the stream
+	in, out int64
+}
+
+// newDecoder creates a decoder for the range coder stream read from br,
+// which starts after in compressed and out decompressed bytes.
+func newDecoder(br io.ByteReader, state *state, dict *decoderDict, size, in, out int64) (*decoder, error) {
+	d := &decoder{
+		dict:  dict,
+		state: state,
+		start: dict.Pos(),
+		size:  size,
+		in:    in,
+		out:   out,
+	}
+	var err error
+	if d.rd, err = newRangeDecoder(br); err != nil {
+		e := &CorruptInputError{Offset: in, Pos: out}
+		switch err {
+		case io.EOF, io.ErrUnexpectedEOF:
+			e.Reason, e.Err = ReasonTruncated, io.ErrUnexpectedEOF
+		case errFirstByte:
+			e.Reason = ReasonFirstByte
+		case errRangeCode:
+			e.Offset += 4
+			e.Reason = ReasonRangeCoder
+		default:
+			return nil, err
+		}
+		return nil, e
+	}
+	return d, nil
+}
+
+// corrupt returns the error for corrupt input at the current position.
+func (d *decoder) corrupt(r CorruptReason, err error) error {
+	return &CorruptInputError{
+		Offset: d.in + d.rd.read,
+		Pos:    d.out + d.Decompressed(),
+		Reason: r,
+		Err:    err,
+	}
+}
+
+func (d *decoder) decodeLiteral() (lit, error) {
+	state, _, _ := d.state.states(d.dict.Pos())
+	litState := d.state.litState(d.dict.ByteAt(1), d.dict.Pos())
+	match := d.dict.ByteAt(int(d.state.rep[0]) + 1)
+	b, err := d.state.litCodec.Decode(d.rd, state, match, litState)
+	if err != nil {
+		return lit{}, err
+	}
+	return lit{b}, nil
+}
+
+func (d *decoder) readOp() (operation, error) {
+	state, state2, posState := d.state.states(d.dict.Pos())
+
+	b, err := d.state.isMatch[state2].Decode(d.rd)
+	if err != nil {
+		return nil, err
+	}
+	if b == 0 {
+		d.kind = OpLit
+		if state >= 7 {
+			d.kind = OpMatchedLit
+		}
+		l, err := d.decodeLiteral()
+		if err != nil {
+			return nil, err
+		}
+		d.state.updateStateLiteral()
+		return l, nil
+	}
+	if b, err = d.state.isRep[state].Decode(d.rd); err != nil {
+		return nil, err
+	}
+	if b == 0 {
+		d.state.rep[3], d.state.rep[2], d.state.rep[1] =
+			d.state.rep[2], d.state.rep[1], d.state.rep[0]
+		d.state.updateStateMatch()
+		n, err := d.state.lenCodec.Decode(d.rd, posState)
+		if err != nil {
+			return nil, err
+		}
+		if d.state.rep[0], err = d.state.distCodec.Decode(d.rd, n); err != nil {
+			return nil, err
+		}
+		if d.state.rep[0] == eosDist {
+			d.kind = OpEOS
+			d.eosMarker = true
+			return nil, errEOS
+		}
+		d.kind = OpMatch
+		return match{n: int(n) + minMatchLen,
+			distance: int64(d.state.rep[0]) + minDistance}, nil
+	}
+	if b, err = d.state.isRepG0[state].Decode(d.rd); err != nil {
+		return nil, err
+	}
+	dist := d.state.rep[0]
+	d.kind = OpRep0
+	if b == 0 {
+		if b, err = d.state.isRepG0Long[state2].Decode(d.rd); err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			d.kind = OpShortRep
+			d.state.updateStateShortRep()
+			return match{n: 1, distance: int64(dist) + minDistance}, nil
+		}
+	} else {
+		if b, err = d.state.isRepG1[state].Decode(d.rd); err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			d.kind = OpRep1
+			dist = d.state.rep[1]
+		} else {
+			if b, err = d.state.isRepG2[state].Decode(d.rd); err != nil {
+				return nil, err
+			}
+			if b == 0 {
+				d.kind = OpRep2
+				dist = d.state.rep[2]
+			} else {
+				d.kind = OpRep3
+				dist = d.state.rep[3]
+				d.state.rep[3] = d.state.rep[2]
+			}
+			d.state.rep[2] = d.state.rep[1]
+		}
+		d.state.rep[1] = d.state.rep[0]
+		d.state.rep[0] = dist
+	}
+	n, err := d.state.repLenCodec.Decode(d.rd, posState)
+	if err != nil {
+		return nil, err
+	}
+	d.state.updateStateRep()
+	return match{n: int(n) + minMatchLen, distance: int64(dist) + minDistance}, nil
+}
+
+func (d *decoder) apply(op operation) error {
+	switch x := op.(type) {
+	case lit:
+		return d.dict.WriteByte(x.b)
+	case match:
+		return d.dict.WriteMatch(x.distance, x.n)
+	default:
+		panic(fmt.Errorf("unexpected operation %T", op))
+	}
+}
+
+func (d *decoder) decompress() error {
+	if d.eos {
+		return io.EOF
+	}
+	for {
+		if d.size >= 0 && d.Decompressed() >= d.size {
+			d.eos = true
+			if d.Decompressed() > d.size {
+				return d.corrupt(ReasonLengthPastSize, nil)
+			}
+			if !d.rd.possiblyAtEnd() {
+				switch _, err := d.readOp(); err {
+				case nil:
+					return d.corrupt(ReasonLengthPastSize, nil)
+				case io.EOF:
+					return d.corrupt(ReasonTruncated,
+						io.ErrUnexpectedEOF)
+				case errEOS:
+				default:
+					return err
+				}
+			}
+			return io.EOF
+		}
+		if d.dict.Available() < maxMatchLen {
+			return nil
+		}
+		var t TraceOp
+		if d.trace != nil {
+			t = d.traceStart()
+		}
+		op, err := d.readOp()
+		if d.trace != nil && (err == nil || err == errEOS) {
+			d.traceEnd(&t, op)
+		}
+		switch err {
+		case nil:
+		case errEOS:
+			d.eos = true
+			if !d.rd.possiblyAtEnd() {
+				return d.corrupt(ReasonDataAfterEOS, nil)
+			}
+			if d.size >= 0 && d.size != d.Decompressed() {
+				return d.corrupt(ReasonSizeMismatch, nil)
+			}
+			return io.EOF
+		case io.EOF:
+			d.eos = true
+			return d.corrupt(ReasonTruncated, io.ErrUnexpectedEOF)
+		default:
+			return err
+		}
+		if d.size >= 0 && d.Decompressed()+int64(op.Len()) > d.size {
+			return d.corrupt(ReasonLengthPastSize, nil)
+		}
+		if err = d.apply(op); err != nil {
+			return d.corrupt(ReasonDistance, err)
+		}
+		if d.lim != nil {
+			if err = d.lim.check(d); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// traceStart records the context of the next operation.
+func (d *decoder) traceStart() TraceOp {
+	pos := d.dict.Pos()
+	return TraceOp{
+		Pos:         pos,
+		StateBefore: int(d.state.state),
+		LitState:    int(d.state.litState(d.dict.ByteAt(1), pos)),
+		MatchByte:   d.dict.ByteAt(int(d.state.rep[0]) + 1),
+		BitPos:      d.rd.bitPos(),
+	}
+}
+
+// traceEnd completes the trace of operation op and passes it to the trace
+// function.
+func (d *decoder) traceEnd(t *TraceOp, op operation) {
+	t.Kind = d.kind
+	t.Rep = repOf(d.kind)
+	t.StateAfter = int(d.state.state)
+	t.Bits = d.rd.bitPos() - t.BitPos
+	switch x := op.(type) {
+	case lit:
+		t.Byte, t.Len = x.b, 1
+	case match:
+		t.Len, t.Distance = x.n, x.distance
+	}
+	if t.Kind != OpMatchedLit {
+		t.MatchByte = 0
+	}
+	d.trace(*t)
+}
+
+func (d *decoder) Read(p []byte) (n int, err error) {
+	if d.err != nil && !d.salvage {
+		return 0, d.err
+	}
+	for {
+		k, _ := d.dict.Read(p[n:])
+		n += k
+		if n >= len(p) {
+			return n, nil
+		}
+		if d.err != nil {
+			return n, d.err
+		}
+		if k == 0 && d.eos {
+			return n, io.EOF
+		}
+		if err = d.decompress(); err != nil && err != io.EOF {
+			d.err = err
+			if !d.salvage {
+				return n, err
+			}
+		}
+	}
+}
+
+// verify decodes the complete stream without providing the decompressed
+// data. The function f is called for all decoded bytes if it is not nil.
+func (d *decoder) verify(f func(p []byte)) error {
+	if d.err != nil {
+		return d.err
+	}
+	for {
+		d.dict.drain(f)
+		if d.eos {
+			return nil
+		}
+		if err := d.decompress(); err != nil && err != io.EOF {
+			d.err = err
+			return err
+		}
+	}
+}
+
+func (d *decoder) Decompressed() int64 {
+	return d.dict.Pos() - d.start
+}
@@ -0,0 +1,113 @@
+package lzma
+
+import (
+	"errors"
+	"fmt"
+)
+
+type decoderDict struct {
+	buf      buffer
+	head     int64
+	capacity int
+}
+
+func newDecoderDict(dictCap int) (*decoderDict, error) {
+	if dictCap < 1 || int64(dictCap) > MaxDictCap {
+		return nil, errors.New("lzma: dictionary capacity out of range")
+	}
+	d := &decoderDict{
+		buf:      *newBuffer(dictCap),
+		capacity: dictCap,
+	}
+	return d, nil
+}
+
+func (d *decoderDict) Reset() {
+	d.buf.Reset()
+	d.head = 0
+}
+
+func (d *decoderDict) WriteByte(c byte) error {
+	if err := d.buf.WriteByte(c); err != nil {
+		return err
+	}
+	d.head++
+	return nil
+}
+
+func (d *decoderDict) Pos() int64 { return d.head }
+
+func (d *decoderDict) DictLen() int {
+	if d.head < int64(d.capacity) {
+		return int(d.head)
+	}
+	return d.capacity
+}
+
+func (d *decoderDict) ByteAt(distance int) byte {
+	if distance <= 0 || distance > d.DictLen() {
+		return 0
+	}
+	i := d.buf.front - distance
+	if i < 0 {
+		i += len(d.buf.data)
+	}
+	return d.buf.data[i]
+}
+
+func (d *decoderDict) WriteMatch(distance int64, n int) error {
+	if distance <= 0 || distance > int64(d.DictLen()) {
+		return fmt.Errorf("match distance %d out of range", distance)
+	}
+	if n <= 0 || n > maxMatchLen {
+		return fmt.Errorf("match length %d out of range", n)
+	}
+	if n > d.buf.Available() {
+		return ErrNoSpace
+	}
+	d.head += int64(n)
+
+	i := d.buf.front - int(distance)
+	if i < 0 {
+		i += len(d.buf.data)
+	}
+	for n > 0 {
+		var p []byte
+		if i >= d.buf.front {
+			p = d.buf.data[i:]
+			i = 0
+		} else {
+			p = d.buf.data[i:d.buf.front]
+			i = d.buf.front
+		}
+		if len(p) > n {
+			p = p[:n]
+		}
+		if _, err := d.buf.Write(p); err != nil {
+			panic(fmt.Errorf("lzma: buffer write failed: %v", err))
+		}
+		n -= len(p)
+	}
+	return nil
+}
+
+func (d *decoderDict) Available() int { return d.buf.Available() }
+
+func (d *decoderDict) Read(p []byte) (int, error) { return d.buf.Read(p) }
+
+func (d *decoderDict) Buffered() int { return d.buf.Buffered() }
+
+// drain removes all buffered bytes without copying them. If f is not nil,
+// it is called for the buffered bytes, which may be split into two slices.
+func (d *decoderDict) drain(f func(p []byte)) {
+	b := &d.buf
+	if f != nil {
+		if b.rear <= b.front {
+			f(b.data[b.rear:b.front])
+		} else {
+			f(b.data[b.rear:])
+			f(b.data[:b.front])
+		}
+	}
+	b.rear = b.front
+}
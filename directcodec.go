@@ -10,3 +10,15 @@ func (dc directCodec) Encode(e *rangeEncoder, v uint32) error {
 	}
 	return nil
 }
+
+func (dc directCodec) Decode(d *rangeDecoder) (uint32, error) {
+	var v uint32
+	for i := 0; i < int(dc); i++ {
+		x, err := d.DirectDecodeBit()
+		if err != nil {
+			return 0, err
+		}
+		v = (v << 1) | x
+	}
+	return v, nil
+}
@@ -0,0 +1,58 @@
+package lzma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// disasmHeader describes the columns written by Disassemble.
+const disasmHeader = "#     offset state kind      len       dist  lit   ctx match   bits"
+
+// disasmLine formats an operation for the disassembler. The offset is
+// given in bits from the start of the file.
+func disasmLine(t TraceOp) string {
+	lit, ctx, mb := "   -", "    -", "    -"
+	switch t.Kind {
+	case OpMatchedLit:
+		mb = fmt.Sprintf(" 0x%02x", t.MatchByte)
+		fallthrough
+	case OpLit:
+		lit = fmt.Sprintf("0x%02x", t.Byte)
+		ctx = fmt.Sprintf("%5d", t.LitState)
+	}
+	offset := 8*(HeaderLen+1) + t.BitPos
+	return fmt.Sprintf("%12.2f %5d %-8s %3d %10d %s %s %s %6.2f",
+		offset, t.StateBefore, t.Kind, t.Len, t.Distance, lit, ctx, mb,
+		t.Bits)
+}
+
+// Disassemble decodes the LZMA stream read from r and writes a line for
+// every operation to w. Each line provides the bit offset of the operation
+// in the compressed file, the state, the kind of the operation, its length
+// and distance, the literal context and the number of bits used.
+func Disassemble(r io.Reader, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var werr error
+	cfg := ReaderConfig{Trace: func(t TraceOp) {
+		if werr == nil {
+			_, werr = fmt.Fprintln(bw, disasmLine(t))
+		}
+	}}
+	lr, err := cfg.NewReader(r)
+	if err != nil {
+		return err
+	}
+	p := lr.h.properties
+	fmt.Fprintf(bw, "# lc %d lp %d pb %d dictCap %d size %d\n",
+		p.LC, p.LP, p.PB, lr.h.dictCap, lr.h.size)
+	fmt.Fprintln(bw, disasmHeader)
+	if _, err = io.Copy(io.Discard, lr); err != nil {
+		bw.Flush()
+		return err
+	}
+	if werr != nil {
+		return werr
+	}
+	return bw.Flush()
+}
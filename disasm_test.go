@@ -0,0 +1,72 @@
+package lzma
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	var ops []TraceOp
+	lzma := compressWith(t, WriterConfig{Trace: func(op TraceOp) {
+		ops = append(ops, op)
+	}}, testData(20000))
+	var out bytes.Buffer
+	if err := Disassemble(bytes.NewReader(lzma), &out); err != nil {
+		t.Fatalf("Disassemble: %v", err)
+	}
+
+	s := bufio.NewScanner(&out)
+	for i := 0; i < 2; i++ {
+		if !s.Scan() || !strings.HasPrefix(s.Text(), "#") {
+			t.Fatalf("header line %d missing", i)
+		}
+	}
+	n := 0
+	for ; s.Scan(); n++ {
+		if n >= len(ops) {
+			t.Fatalf("more lines than operations")
+		}
+		op := ops[n]
+		f := strings.Fields(s.Text())
+		if len(f) != 9 {
+			t.Fatalf("line %d: %q has %d fields", n, s.Text(), len(f))
+		}
+		if f[2] != op.Kind.String() {
+			t.Fatalf("line %d: kind %s; want %s", n, f[2], op.Kind)
+		}
+		num := func(i int) float64 {
+			x, err := strconv.ParseFloat(f[i], 64)
+			if err != nil {
+				t.Fatalf("line %d: %v", n, err)
+			}
+			return x
+		}
+		if got := int(num(1)); got != op.StateBefore {
+			t.Fatalf("line %d: state %d; want %d", n, got,
+				op.StateBefore)
+		}
+		if got := int(num(3)); got != op.Len {
+			t.Fatalf("line %d: len %d; want %d", n, got, op.Len)
+		}
+		if got := int64(num(4)); got != op.Distance {
+			t.Fatalf("line %d: dist %d; want %d", n, got, op.Distance)
+		}
+		offset := 8*(HeaderLen+1) + op.BitPos
+		if got := num(0); math.Abs(got-offset) > 0.01 {
+			t.Fatalf("line %d: offset %.2f; want %.2f", n, got, offset)
+		}
+		if got := num(8); math.Abs(got-op.Bits) > 0.01 {
+			t.Fatalf("line %d: bits %.2f; want %.2f", n, got, op.Bits)
+		}
+	}
+	if n != len(ops) {
+		t.Fatalf("got %d lines; want %d", n, len(ops))
+	}
+	if ops[n-1].Kind != OpEOS {
+		t.Fatalf("last operation %v; want EOS", ops[n-1])
+	}
+}
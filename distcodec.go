@@ -42,16 +42,20 @@ func lenState(l uint32) uint32 {
 	return l
 }
 
-func (dc *distCodec) Encode(e *rangeEncoder, dist, l uint32) error {
-	var posSlot uint32
-	var bits uint32
+// posSlot returns the position slot for the distance value and the number
+// of bits following the slot.
+func posSlot(dist uint32) (slot uint32, bits uint32) {
 	if dist < startPosModel {
-		posSlot = dist
-	} else {
-		bits = uint32(30 - nlz32(dist))
-		posSlot = startPosModel - 2 + (bits << 1)
-		posSlot += (dist >> uint(bits)) & 1
+		return dist, 0
 	}
+	bits = uint32(30 - nlz32(dist))
+	slot = startPosModel - 2 + (bits << 1)
+	slot += (dist >> uint(bits)) & 1
+	return slot, bits
+}
+
+func (dc *distCodec) Encode(e *rangeEncoder, dist, l uint32) error {
+	posSlot, bits := posSlot(dist)
 
 	if err := dc.posSlotCodecs[lenState(l)].Encode(e, posSlot); err != nil {
 		return err
@@ -70,3 +74,32 @@ func (dc *distCodec) Encode(e *rangeEncoder, dist, l uint32) error {
 	}
 	return dc.alignCodec.Encode(dist, e)
 }
+
+func (dc *distCodec) Decode(d *rangeDecoder, l uint32) (uint32, error) {
+	posSlot, err := dc.posSlotCodecs[lenState(l)].Decode(d)
+	if err != nil {
+		return 0, err
+	}
+	if posSlot < startPosModel {
+		return posSlot, nil
+	}
+	bits := (posSlot >> 1) - 1
+	dist := (2 | (posSlot & 1)) << bits
+	var u uint32
+	if posSlot < endPosModel {
+		tc := &dc.posModel[posSlot-startPosModel]
+		if u, err = tc.Decode(d); err != nil {
+			return 0, err
+		}
+		return dist + u, nil
+	}
+	dic := directCodec(bits - alignBits)
+	if u, err = dic.Decode(d); err != nil {
+		return 0, err
+	}
+	dist += u << alignBits
+	if u, err = dc.alignCodec.Decode(d); err != nil {
+		return 0, err
+	}
+	return dist + u, nil
+}
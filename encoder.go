@@ -27,6 +27,15 @@ type encoder struct {
 	marker bool
 	limit  bool
 	margin int
+	stats  Stats
+	trace  func(TraceOp)
+	// matches aren't searched in blocks whose entropy reaches the
+	// threshold; blockEnd ends the block checked last and skipEnd the
+	// skipped range
+	entropyThreshold float64
+	block            []byte
+	blockEnd         int64
+	skipEnd          int64
 }
 
 func newEncoder(bw io.ByteWriter, state *state, dict *encoderDict, flags encoderFlags) (*encoder, error) {
@@ -91,19 +100,15 @@ func (e *encoder) writeMatch(m match) error {
 		panic(fmt.Errorf("match distance %d out of range", m.distance))
 	}
 	dist := uint32(m.distance - minDistance)
-	if (m.n < minMatchLen || m.n > maxMatchLen) || (dist != e.state.rep[0] || m.n != 1) {
+	if (m.n < minMatchLen || m.n > maxMatchLen) &&
+		!(dist == e.state.rep[0] && m.n == 1) {
 		panic(fmt.Errorf("match length %d out of range; dist %d rep[0] %d", m.n, dist, e.state.rep[0]))
 	}
 	state, state2, posState := e.state.states(e.dict.Pos())
 	if err = e.state.isMatch[state2].Encode(e.re, 1); err != nil {
 		return err
 	}
-	g := 0
-	for ; g < 4; g++ {
-		if e.state.rep[g] == dist {
-			break
-		}
-	}
+	g := e.state.repIndex(dist)
 	b := iverson(g < 4)
 	if err = e.state.isRep[state].Encode(e.re, b); err != nil {
 		return err
@@ -158,11 +163,55 @@ func (e *encoder) writeOp(op operation) error {
 	if e.re.Available() < int64(e.margin) {
 		return ErrLimit
 	}
+	start := e.re.fixedBitPos()
+	var traceStart float64
+	if e.trace != nil {
+		traceStart = e.re.bitPos()
+	}
+	before := e.state.state
+	pos := e.dict.Pos()
 	switch x := op.(type) {
 	case lit:
-		return e.writeLiteral(x)
+		matched := before >= 7
+		if err := e.writeLiteral(x); err != nil {
+			return err
+		}
+		bits := float64(e.re.fixedBitPos()-start) / bitPosScale
+		e.stats.addLiteral(matched, bits)
+		if e.trace != nil {
+			t := TraceOp{Kind: OpLit, Pos: pos, Byte: x.b, Len: 1,
+				Rep: -1, StateBefore: int(before),
+				StateAfter: int(e.state.state),
+				LitState:   int(e.state.litState(e.dict.ByteAt(1), pos)),
+				BitPos:     traceStart,
+				Bits:       e.re.bitPos() - traceStart}
+			if matched {
+				t.Kind = OpMatchedLit
+				t.MatchByte = e.dict.ByteAt(int(e.state.rep[0]) + 1)
+			}
+			e.trace(t)
+		}
+		return nil
 	case match:
-		return e.writeMatch(x)
+		g := e.state.repIndex(uint32(x.distance - minDistance))
+		if err := e.writeMatch(x); err != nil {
+			return err
+		}
+		bits := float64(e.re.fixedBitPos()-start) / bitPosScale
+		k := matchKind(x, g)
+		e.stats.addMatch(x, k, bits)
+		if e.trace != nil {
+			if g == 4 {
+				g = -1
+			}
+			e.trace(TraceOp{Kind: k, Pos: pos, Len: x.n,
+				Distance: x.distance, Rep: g,
+				StateBefore: int(before),
+				StateAfter:  int(e.state.state),
+				BitPos:      traceStart,
+				Bits:        e.re.bitPos() - traceStart})
+		}
+		return nil
 	default:
 		panic("unexpected operation")
 	}
@@ -176,7 +225,18 @@ func (e *encoder) compress(flags compressFlags) error {
 	d := e.dict
 	m := d.m
 	for d.Buffered() > n {
-		op := m.NextOp(e.state.rep)
+		if e.entropyThreshold > 0 && !e.checkEntropy(flags) {
+			break
+		}
+		var op operation
+		if e.entropyThreshold > 0 && d.Pos() < e.skipEnd {
+			var c [1]byte
+			d.buf.Peek(c[:])
+			op = lit{c[0]}
+			e.stats.SkippedBytes++
+		} else {
+			op = m.NextOp(e.state.rep)
+		}
 		if err := e.writeOp(op); err != nil {
 			return err
 		}
@@ -185,6 +245,36 @@ func (e *encoder) compress(flags compressFlags) error {
 	return nil
 }
 
+// checkEntropy checks the entropy of the block starting at the current
+// position if the previous block has been encoded. The block is skipped by
+// the match search if its entropy reaches the threshold. The function
+// returns false if the block is not completely buffered and more data may
+// follow; only a final block may be shorter.
+func (e *encoder) checkEntropy(flags compressFlags) bool {
+	d := e.dict
+	pos := d.Pos()
+	if pos < e.blockEnd {
+		return true
+	}
+	// The lookahead buffer may be smaller than a block.
+	n := entropyBlockLen
+	if k := d.buf.Cap() - d.capacity; k < n {
+		n = k
+	}
+	if d.Buffered() < n && flags&all == 0 {
+		return false
+	}
+	if e.block == nil {
+		e.block = make([]byte, entropyBlockLen)
+	}
+	k, _ := d.buf.Peek(e.block[:n])
+	e.blockEnd = pos + int64(k)
+	if byteEntropy(e.block[:k]) >= e.entropyThreshold {
+		e.skipEnd = e.blockEnd
+	}
+	return true
+}
+
 var eosMatch = match{distance: maxDistance, n: minMatchLen}
 
 
@@ -194,9 +284,17 @@ func (e *encoder) Close() error {
 		return err
 	}
 	if e.marker {
+		start := e.re.bitPos()
+		before := e.state.state
 		if err := e.writeMatch(eosMatch); err != nil {
 			return err
 		}
+		if e.trace != nil {
+			e.trace(TraceOp{Kind: OpEOS, Pos: e.dict.Pos(), Rep: -1,
+				StateBefore: int(before),
+				StateAfter:  int(e.state.state),
+				BitPos:      start, Bits: e.re.bitPos() - start})
+		}
 	}
 	err = e.re.Close()
 	return err
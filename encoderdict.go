@@ -80,6 +80,17 @@ func (d *encoderDict) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// WriteByte appends c to the dictionary and moves the head behind it. The
+// lookahead buffer must be empty.
+func (d *encoderDict) WriteByte(c byte) error {
+	d.data[0] = c
+	if _, err := d.Write(d.data[:1]); err != nil {
+		return err
+	}
+	d.Discard(1)
+	return nil
+}
+
 func (d *encoderDict) Pos() int64 { return d.head }
 
 func (d *encoderDict) ByteAt(distance int) byte {
@@ -94,3 +105,31 @@ func (d *encoderDict) ByteAt(distance int) byte {
 }
 
 func (d *encoderDict) Buffered() int { return d.buf.Buffered() }
+
+// WriteMatch appends the n bytes at the given distance from the head to the
+// dictionary and moves the head behind them. The lookahead buffer must be
+// empty.
+func (d *encoderDict) WriteMatch(distance int64, n int) error {
+	if distance <= 0 || distance > int64(d.DictLen()) {
+		return fmt.Errorf("lzma: match distance %d out of range", distance)
+	}
+	if n <= 0 || n > maxMatchLen {
+		return fmt.Errorf("lzma: match length %d out of range", n)
+	}
+	if d.Buffered() > 0 {
+		panic("lzma: WriteMatch requires an empty lookahead buffer")
+	}
+	dist := int(distance)
+	for i := 0; i < n; i++ {
+		if i < dist {
+			d.data[i] = d.ByteAt(dist - i)
+		} else {
+			d.data[i] = d.data[i-dist]
+		}
+	}
+	if _, err := d.Write(d.data[:n]); err != nil {
+		return err
+	}
+	d.Discard(n)
+	return nil
+}
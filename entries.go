@@ -0,0 +1,192 @@
+package lzma
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Entry locates a file in the uncompressed data of a solid stream.
+type Entry struct {
+	Name   string
+	Offset int64
+	Size   int64
+}
+
+// inputSize returns the number of bytes written to the writer.
+func (w *Writer) inputSize() int64 {
+	if w.e == nil {
+		return int64(len(w.sample))
+	}
+	return w.e.Compressed() + int64(w.e.dict.Buffered())
+}
+
+// maxEntryName is the maximum length of an entry name.
+const maxEntryName = 1 << 16
+
+var errEntryName = errors.New("lzma: entry name must have 1 to 65536 bytes")
+
+// NextEntry starts a new entry at the current uncompressed offset. The
+// dictionary and the model are kept, so all entries share them. The
+// entries are recorded in an index provided by Entries. Entries may be
+// empty, but their names must not. An error is also returned if the offset
+// precedes the previous entry, which happens only for a Writer restored
+// from an inconsistent checkpoint.
+func (w *Writer) NextEntry(name string) error {
+	if len(name) == 0 || len(name) > maxEntryName {
+		return errEntryName
+	}
+	off := w.inputSize()
+	if k := len(w.entries); k > 0 && off < w.entries[k-1].Offset {
+		return errIndex
+	}
+	w.entries = append(w.entries, Entry{Name: name, Offset: off})
+	return nil
+}
+
+// Entries returns the index of the entries started by NextEntry. The sizes
+// count every byte passed to Write, including data still held in the
+// AutoProperties sample or the lookahead buffer of the encoder, so the
+// size of the last entry covers all data written so far.
+func (w *Writer) Entries() []Entry {
+	entries := make([]Entry, len(w.entries))
+	copy(entries, w.entries)
+	end := w.inputSize()
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].Size = end - entries[i].Offset
+		end = entries[i].Offset
+	}
+	return entries
+}
+
+// indexMagic and indexVersion start the encoding of an entry index.
+var indexMagic = []byte("LZIX")
+
+const indexVersion = 1
+
+// WriteIndex writes the entries in a binary format. After magic and
+// version the number of entries follows, then name length, name, offset
+// and size of every entry. All numbers are unsigned varints.
+func WriteIndex(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(indexMagic)
+	bw.WriteByte(indexVersion)
+	var buf [binary.MaxVarintLen64]byte
+	putUvarint := func(x uint64) {
+		n := binary.PutUvarint(buf[:], x)
+		bw.Write(buf[:n])
+	}
+	putUvarint(uint64(len(entries)))
+	for _, e := range entries {
+		putUvarint(uint64(len(e.Name)))
+		bw.WriteString(e.Name)
+		putUvarint(uint64(e.Offset))
+		putUvarint(uint64(e.Size))
+	}
+	return bw.Flush()
+}
+
+var errIndex = errors.New("lzma: invalid entry index")
+
+// ReadIndex reads entries written by WriteIndex.
+func ReadIndex(r io.Reader) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	var hdr [5]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, errIndex
+	}
+	if string(hdr[:4]) != string(indexMagic) {
+		return nil, errIndex
+	}
+	if hdr[4] != indexVersion {
+		return nil, fmt.Errorf("lzma: unsupported index version %d", hdr[4])
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, errIndex
+	}
+	var entries []Entry
+	var end int64
+	for i := uint64(0); i < n; i++ {
+		k, err := binary.ReadUvarint(br)
+		if err != nil || k > maxEntryName {
+			return nil, errIndex
+		}
+		name := make([]byte, k)
+		if _, err = io.ReadFull(br, name); err != nil {
+			return nil, errIndex
+		}
+		off, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, errIndex
+		}
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, errIndex
+		}
+		e := Entry{Name: string(name), Offset: int64(off), Size: int64(size)}
+		if e.Offset < end || e.Size < 0 || e.Offset+e.Size < e.Offset {
+			return nil, errIndex
+		}
+		end = e.Offset + e.Size
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// EntryReader reads the entries of a solid stream one after the other.
+type EntryReader struct {
+	r       io.Reader
+	entries []Entry
+	next    int
+	pos     int64
+	end     int64
+}
+
+// NewEntryReader creates a reader for the entries of the decompressed data
+// provided by r, for instance a Reader. The entries must be sorted by
+// offset, as returned by Writer.Entries.
+func NewEntryReader(r io.Reader, entries []Entry) *EntryReader {
+	return &EntryReader{r: r, entries: entries}
+}
+
+// Next skips the rest of the current entry and returns the next one. It
+// returns io.EOF after the last entry.
+func (r *EntryReader) Next() (*Entry, error) {
+	if r.next >= len(r.entries) {
+		return nil, io.EOF
+	}
+	e := &r.entries[r.next]
+	if e.Offset < r.pos {
+		return nil, errIndex
+	}
+	if _, err := io.CopyN(io.Discard, r.r, e.Offset-r.pos); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	r.next++
+	r.pos = e.Offset
+	r.end = e.Offset + e.Size
+	return e, nil
+}
+
+// Read reads from the current entry. It returns io.EOF at the end of the
+// entry.
+func (r *EntryReader) Read(p []byte) (n int, err error) {
+	if r.pos >= r.end {
+		return 0, io.EOF
+	}
+	if m := r.end - r.pos; int64(len(p)) > m {
+		p = p[:m]
+	}
+	n, err = r.r.Read(p)
+	r.pos += int64(n)
+	if err == io.EOF && r.pos < r.end {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,111 @@
+package lzma
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEntries(t *testing.T) {
+	files := make([][]byte, 5)
+	for i := range files {
+		files[i] = testData(1000 * i * i)
+	}
+	var out bytes.Buffer
+	w, err := NewWriter(&out)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for i, f := range files {
+		if err = w.NextEntry(fmt.Sprintf("file%d", i)); err != nil {
+			t.Fatalf("NextEntry: %v", err)
+		}
+		if _, err = w.Write(f); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	entries := w.Entries()
+	var index bytes.Buffer
+	if err = WriteIndex(&index, entries); err != nil {
+		t.Fatalf("WriteIndex: %v", err)
+	}
+	p := index.Bytes()
+	if entries, err = ReadIndex(bytes.NewReader(p)); err != nil {
+		t.Fatalf("ReadIndex: %v", err)
+	}
+	if !reflect.DeepEqual(entries, w.Entries()) {
+		t.Fatalf("index read %v; want %v", entries, w.Entries())
+	}
+	for _, q := range [][]byte{nil, p[:len(p)-1], []byte("LZIX\x02")} {
+		if _, err = ReadIndex(bytes.NewReader(q)); err == nil {
+			t.Errorf("ReadIndex accepted %q", q)
+		}
+	}
+
+	r, err := NewReader(&out)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	er := NewEntryReader(r, entries)
+	// Skip the even entries without reading them.
+	for i := 0; ; i++ {
+		e, err := er.Next()
+		if err == io.EOF {
+			if i != len(files) {
+				t.Fatalf("%d entries; want %d", i, len(files))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if e.Name != fmt.Sprintf("file%d", i) {
+			t.Fatalf("entry %d has name %q", i, e.Name)
+		}
+		if i%2 == 0 {
+			continue
+		}
+		got, err := io.ReadAll(er)
+		if err != nil {
+			t.Fatalf("reading entry %d: %v", i, err)
+		}
+		if !bytes.Equal(got, files[i]) {
+			t.Fatalf("entry %d differs", i)
+		}
+	}
+}
+
+func TestNextEntryErrors(t *testing.T) {
+	w, err := WriterConfig{AutoProperties: true}.NewWriter(io.Discard)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, name := range []string{"", strings.Repeat("x", maxEntryName+1)} {
+		if err = w.NextEntry(name); err == nil {
+			t.Errorf("NextEntry accepted a name of %d bytes", len(name))
+		}
+	}
+	// Sizes include the data buffered for the sample.
+	for _, name := range []string{"a", "b"} {
+		if err = w.NextEntry(name); err != nil {
+			t.Fatalf("NextEntry: %v", err)
+		}
+		if _, err = w.Write(testData(100)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	want := []Entry{{"a", 0, 100}, {"b", 100, 100}}
+	if got := w.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got entries %v; want %v", got, want)
+	}
+	w.entries[1].Offset = 1000
+	if err = w.NextEntry("c"); err == nil {
+		t.Errorf("NextEntry accepted an offset before the previous entry")
+	}
+}
@@ -0,0 +1,27 @@
+package lzma
+
+import "math"
+
+// entropyBlockLen is the size of the blocks whose entropy is checked by
+// the encoder.
+const entropyBlockLen = 4096
+
+// byteEntropy returns the order-0 entropy of p in bits per byte.
+func byteEntropy(p []byte) float64 {
+	if len(p) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range p {
+		counts[c]++
+	}
+	n := float64(len(p))
+	h := 0.0
+	for _, k := range counts {
+		if k > 0 {
+			q := float64(k) / n
+			h -= q * math.Log2(q)
+		}
+	}
+	return h
+}
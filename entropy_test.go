@@ -0,0 +1,63 @@
+package lzma
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestEntropyThreshold(t *testing.T) {
+	random := make([]byte, 20000)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := testData(20000)
+	data := append(append(append([]byte(nil), text...), random...), text...)
+	cfg := WriterConfig{EntropyThreshold: 7.5}
+
+	var buf bytes.Buffer
+	w, err := cfg.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	n := w.Stats().SkippedBytes
+	if n < int64(len(random))-2*entropyBlockLen || n > int64(len(random))+entropyBlockLen {
+		t.Errorf("skipped %d bytes for %d random bytes", n, len(random))
+	}
+	got, err := Decompress(nil, buf.Bytes())
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decompressed data differs")
+	}
+
+	// The estimator encodes after every write; small writes must not
+	// change the blocks checked.
+	est, err := cfg.NewEstimator()
+	if err != nil {
+		t.Fatalf("NewEstimator: %v", err)
+	}
+	for p := data; len(p) > 0; {
+		k := 100
+		if k > len(p) {
+			k = len(p)
+		}
+		if _, err = est.Write(p[:k]); err != nil {
+			t.Fatalf("Estimator.Write: %v", err)
+		}
+		p = p[k:]
+	}
+	e, err := est.Close()
+	if err != nil {
+		t.Fatalf("Estimator.Close: %v", err)
+	}
+	if e.Output != int64(buf.Len()) {
+		t.Errorf("estimator with small writes reports %d bytes; writer %d",
+			e.Output, buf.Len())
+	}
+}
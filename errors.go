@@ -0,0 +1,104 @@
+package lzma
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCorruptInput matches every CorruptInputError using errors.Is.
+var ErrCorruptInput = errors.New("lzma: corrupt input")
+
+// ErrInvalidConfig matches every ConfigError using errors.Is.
+var ErrInvalidConfig = errors.New("lzma: invalid configuration")
+
+// CorruptReason describes why the input has been rejected as corrupt.
+type CorruptReason int
+
+// Reasons for corrupt input.
+const (
+	ReasonBadProperties CorruptReason = iota + 1
+	ReasonBadHeader
+	ReasonDistance
+	ReasonLengthPastSize
+	ReasonSizeMismatch
+	ReasonFirstByte
+	ReasonRangeCoder
+	ReasonDataAfterEOS
+	ReasonMissingEOS
+	ReasonChecksum
+	ReasonTruncated
+	ReasonTrailingData
+)
+
+var reasonStrings = map[CorruptReason]string{
+	ReasonBadProperties:  "bad properties",
+	ReasonBadHeader:      "bad header",
+	ReasonDistance:       "distance beyond dictionary",
+	ReasonLengthPastSize: "length past known size",
+	ReasonSizeMismatch:   "wrong uncompressed size",
+	ReasonFirstByte:      "nonzero first range coder byte",
+	ReasonRangeCoder:     "invalid range coder state",
+	ReasonDataAfterEOS:   "data after EOS marker",
+	ReasonMissingEOS:     "missing EOS marker",
+	ReasonChecksum:       "checksum mismatch",
+	ReasonTruncated:      "truncated input",
+	ReasonTrailingData:   "trailing garbage",
+}
+
+func (r CorruptReason) String() string {
+	if s, ok := reasonStrings[r]; ok {
+		return s
+	}
+	return "unknown reason"
+}
+
+// CorruptInputError reports corrupt compressed data. Offset is the
+// compressed byte offset at which the damage has been detected and Pos the
+// number of bytes decompressed up to this point. Err may provide an
+// underlying error; for truncated input it is io.ErrUnexpectedEOF.
+type CorruptInputError struct {
+	Offset int64
+	Pos    int64
+	Reason CorruptReason
+	Err    error
+}
+
+func (e *CorruptInputError) Error() string {
+	s := fmt.Sprintf("lzma: %s at offset %d (uncompressed position %d)",
+		e.Reason, e.Offset, e.Pos)
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *CorruptInputError) Unwrap() error { return e.Err }
+
+func (e *CorruptInputError) Is(target error) bool {
+	return target == ErrCorruptInput
+}
+
+// trailingData converts an error for the start of a further stream or
+// member into the error reporting trailing garbage at offset in. Other
+// errors are returned unchanged.
+func trailingData(err error, in, out int64) error {
+	if _, ok := err.(*CorruptInputError); !ok {
+		return err
+	}
+	return &CorruptInputError{Offset: in, Pos: out,
+		Reason: ReasonTrailingData}
+}
+
+// ConfigError reports an invalid field of a configuration.
+type ConfigError struct {
+	Field string
+	Msg   string
+}
+
+func (e *ConfigError) Error() string {
+	return "lzma: " + e.Msg
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
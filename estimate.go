@@ -0,0 +1,92 @@
+package lzma
+
+import (
+	"io"
+	"math"
+)
+
+// Estimate provides the compressed size predicted for Input bytes.
+type Estimate struct {
+	Input  int64
+	Output int64
+}
+
+// Ratio returns the predicted compressed size relative to the input size.
+func (e Estimate) Ratio() float64 {
+	if e.Input == 0 {
+		return 0
+	}
+	return float64(e.Output) / float64(e.Input)
+}
+
+// Estimator runs the encoder on the data written to it and counts the
+// range coder output without keeping it.
+type Estimator struct {
+	w *Writer
+}
+
+// NewEstimator creates an estimator for the configuration. The fields
+// Size, SizeInHeader, PatchSize, AutoProperties and Trace are ignored.
+func (c WriterConfig) NewEstimator() (*Estimator, error) {
+	c.Size, c.SizeInHeader, c.PatchSize = 0, false, false
+	c.AutoProperties, c.Trace = false, nil
+	w, err := c.NewWriter(discardWriter{})
+	if err != nil {
+		return nil, err
+	}
+	return &Estimator{w: w}, nil
+}
+
+// Write encodes p except the lookahead the encoder needs, so the estimate
+// follows the input closely.
+func (e *Estimator) Write(p []byte) (n int, err error) {
+	if n, err = e.w.Write(p); err != nil {
+		return n, err
+	}
+	return n, e.w.e.compress(0)
+}
+
+// Estimate predicts the size of the complete file for the data written so
+// far. The size of the data still buffered by the encoder is extrapolated
+// from the data already encoded.
+func (e *Estimator) Estimate() Estimate {
+	enc := e.w.e
+	encoded := enc.Compressed()
+	buffered := int64(enc.dict.Buffered())
+	bytes := enc.re.bitPos() / 8
+	if encoded > 0 {
+		bytes += float64(buffered) * bytes / float64(encoded)
+	}
+	// The EOS marker and the flush of the range coder add a few bytes.
+	const tail = 5
+	return Estimate{
+		Input:  encoded + buffered,
+		Output: HeaderLen + int64(math.Ceil(bytes)) + tail,
+	}
+}
+
+// Close encodes the remaining data and returns the exact size of the
+// file.
+func (e *Estimator) Close() (Estimate, error) {
+	if err := e.w.Close(); err != nil {
+		return Estimate{}, err
+	}
+	return Estimate{
+		Input:  e.w.e.Compressed(),
+		Output: HeaderLen + e.w.e.re.written,
+	}, nil
+}
+
+// EstimateCompressedSize returns the size of the .lzma file the
+// configuration would produce for the data read from r. The output of the
+// encoder is counted but not stored.
+func EstimateCompressedSize(r io.Reader, cfg WriterConfig) (Estimate, error) {
+	e, err := cfg.NewEstimator()
+	if err != nil {
+		return Estimate{}, err
+	}
+	if _, err = io.Copy(e, r); err != nil {
+		return Estimate{}, err
+	}
+	return e.Close()
+}
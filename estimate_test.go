@@ -0,0 +1,48 @@
+package lzma
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEstimateCompressedSize(t *testing.T) {
+	for _, data := range [][]byte{nil, testData(1000), testData(100000),
+		recordData(100000)} {
+		e, err := EstimateCompressedSize(bytes.NewReader(data),
+			WriterConfig{})
+		if err != nil {
+			t.Fatalf("EstimateCompressedSize: %v", err)
+		}
+		n := len(compressWith(t, WriterConfig{}, data))
+		if e.Input != int64(len(data)) || e.Output != int64(n) {
+			t.Errorf("got estimate %+v; want %d bytes for %d",
+				e, n, len(data))
+		}
+	}
+}
+
+func TestEstimator(t *testing.T) {
+	// The extrapolation assumes the ratio of the encoded data for the
+	// buffered data. The estimate must be within 2% plus 32 bytes of
+	// the actual size.
+	data := append(testData(100000), recordData(100000)...)
+	est, err := WriterConfig{}.NewEstimator()
+	if err != nil {
+		t.Fatalf("NewEstimator: %v", err)
+	}
+	const chunk = 25000
+	for k := chunk; k <= len(data); k += chunk {
+		if _, err = est.Write(data[k-chunk : k]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		e := est.Estimate()
+		if e.Input != int64(k) {
+			t.Fatalf("estimate for %d bytes; want %d", e.Input, k)
+		}
+		n := int64(len(compressWith(t, WriterConfig{}, data[:k])))
+		if d := e.Output - n; d < -n/50-32 || d > n/50+32 {
+			t.Errorf("k=%d: estimate %d; actual size %d", k,
+				e.Output, n)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package lzma
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// frameHeaderLen is the length of the header that starts a framed
+// connection: the properties byte and the dictionary capacity.
+const frameHeaderLen = 5
+
+// FrameWriter compresses a sequence of messages with a single LZMA
+// context. Dictionary and model persist across messages. Flush terminates
+// a message and writes it as a frame: the compressed length and the
+// uncompressed length as unsigned varints followed by the range coder
+// stream of the message.
+type FrameWriter struct {
+	w     io.Writer
+	e     *encoder
+	buf   bytes.Buffer
+	start int64
+	hdr   []byte
+}
+
+// NewFrameWriter creates a writer for messages. The fields Size,
+// SizeInHeader, EOSMarker, PatchSize and AutoProperties are ignored.
+func (c WriterConfig) NewFrameWriter(w io.Writer) (*FrameWriter, error) {
+	c.Size, c.SizeInHeader, c.PatchSize, c.AutoProperties = 0, false, false, false
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	c.EOSMarker = false
+	f := &FrameWriter{w: w}
+	var err error
+	if f.e, err = c.newEncoder(&f.buf); err != nil {
+		return nil, err
+	}
+	f.hdr = make([]byte, frameHeaderLen)
+	f.hdr[0] = c.Properties.ToByte()
+	binary.LittleEndian.PutUint32(f.hdr[1:], uint32(c.DictCap))
+	return f, nil
+}
+
+// Write adds p to the current message.
+func (f *FrameWriter) Write(p []byte) (int, error) {
+	return f.e.Write(p)
+}
+
+// Flush encodes the rest of the current message and writes its frame.
+// Nothing is written if the message is empty.
+func (f *FrameWriter) Flush() error {
+	e := f.e
+	n := e.Compressed() + int64(e.dict.Buffered()) - f.start
+	if n == 0 {
+		return nil
+	}
+	if err := e.compress(all); err != nil {
+		return err
+	}
+	if err := e.re.Close(); err != nil {
+		return err
+	}
+	var frame []byte
+	if f.hdr != nil {
+		frame, f.hdr = f.hdr, nil
+	}
+	frame = appendUvarint(frame, uint64(f.buf.Len()))
+	frame = appendUvarint(frame, uint64(n))
+	frame = append(frame, f.buf.Bytes()...)
+	f.buf.Reset()
+	f.start = e.Compressed()
+	// The next message starts a new range coder stream.
+	var err error
+	if e.re, err = newRangeEncoder(&f.buf); err != nil {
+		return err
+	}
+	_, err = f.w.Write(frame)
+	return err
+}
+
+// Close flushes the last message. It doesn't close the underlying writer.
+func (f *FrameWriter) Close() error {
+	return f.Flush()
+}
+
+func appendUvarint(p []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(p, buf[:n]...)
+}
+
+// FrameReader decompresses the messages written by a FrameWriter.
+type FrameReader struct {
+	cfg   ReaderConfig
+	br    io.ByteReader
+	state *state
+	dict  *decoderDict
+	frame []byte
+	// compressed and decompressed bytes of the previous frames
+	in, out int64
+}
+
+// NewFrameReader creates a reader for framed messages. MaxSize limits the
+// size of every message, MaxRatio and MaxFrameSize apply to every frame.
+// The field DictCap is ignored. The reader never reads beyond the frame of the
+// message requested if r is an io.ByteReader.
+func (c ReaderConfig) NewFrameReader(r io.Reader) (*FrameReader, error) {
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	return &FrameReader{cfg: c, br: byteReader(r)}, nil
+}
+
+// init reads the connection header and creates the context.
+func (f *FrameReader) init() error {
+	var hdr [frameHeaderLen]byte
+	for i := range hdr {
+		c, err := f.br.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				err = &CorruptInputError{Offset: int64(i),
+					Reason: ReasonTruncated, Err: io.ErrUnexpectedEOF}
+			}
+			return err
+		}
+		hdr[i] = c
+	}
+	var p Properties
+	if err := p.fromByte(hdr[0]); err != nil {
+		return &CorruptInputError{Reason: ReasonBadProperties, Err: err}
+	}
+	dictCap := int(binary.LittleEndian.Uint32(hdr[1:]))
+	if dictCap < MinDictCap {
+		dictCap = MinDictCap
+	}
+	if err := f.cfg.checkDictCap(dictCap); err != nil {
+		return err
+	}
+	dict, err := newDecoderDict(dictCap)
+	if err != nil {
+		return err
+	}
+	s, err := f.cfg.newState(p)
+	if err != nil {
+		return err
+	}
+	f.state, f.dict = s, dict
+	f.in = frameHeaderLen
+	return nil
+}
+
+var errFrameLen = errors.New("lzma: frame length out of range")
+
+// maxFrameSize returns the limit for the compressed size of a frame or zero
+// if there is none.
+func (c *ReaderConfig) maxFrameSize() int64 {
+	if c.MaxFrameSize > 0 || c.MaxSize == 0 {
+		return c.MaxFrameSize
+	}
+	// the CompressBound estimate without the header
+	n := c.MaxSize
+	if n > maxInt64/2 {
+		return 0
+	}
+	return n + n/3 + 128
+}
+
+// readFrame reads the next frame and returns the uncompressed size of its
+// message and the length of the frame header.
+func (f *FrameReader) readFrame() (size int64, hlen int, err error) {
+	clen, err := binary.ReadUvarint(f.br)
+	if err != nil {
+		return 0, 0, err
+	}
+	usize, err := binary.ReadUvarint(f.br)
+	if err != nil {
+		return 0, 0, f.truncated(err)
+	}
+	if clen > math.MaxInt || usize > maxInt64 {
+		return 0, 0, &CorruptInputError{Offset: f.in, Pos: f.out,
+			Reason: ReasonBadHeader, Err: errFrameLen}
+	}
+	if m := f.cfg.maxFrameSize(); m > 0 && clen > uint64(m) {
+		return 0, 0, &FrameSizeLimitError{Size: int64(clen), Limit: m}
+	}
+	size = int64(usize)
+	if err = f.cfg.checkSize(size); err != nil {
+		return 0, 0, err
+	}
+	if r := f.cfg.MaxRatio; r > 0 && float64(size) > r*float64(clen) {
+		return 0, 0, &RatioLimitError{Compressed: int64(clen),
+			Decompressed: size, Limit: r}
+	}
+	// The buffer grows with the data actually read, not with the length
+	// announced.
+	f.frame = f.frame[:0]
+	for i := uint64(0); i < clen; i++ {
+		c, err := f.br.ReadByte()
+		if err != nil {
+			return 0, 0, f.truncated(err)
+		}
+		f.frame = append(f.frame, c)
+	}
+	var buf [binary.MaxVarintLen64]byte
+	hlen = binary.PutUvarint(buf[:], clen) + binary.PutUvarint(buf[:], usize)
+	return size, hlen, nil
+}
+
+func (f *FrameReader) truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &CorruptInputError{Offset: f.in, Pos: f.out,
+			Reason: ReasonTruncated, Err: io.ErrUnexpectedEOF}
+	}
+	return err
+}
+
+// ReadMessage reads the next frame and appends the decompressed message to
+// dst. It returns io.EOF if the input ends before the next frame.
+func (f *FrameReader) ReadMessage(dst []byte) ([]byte, error) {
+	if f.dict == nil {
+		if err := f.init(); err != nil {
+			return dst, err
+		}
+	}
+	size, hlen, err := f.readFrame()
+	if err != nil {
+		return dst, err
+	}
+	br := bytes.NewReader(f.frame)
+	in := f.in + int64(hlen)
+	d, err := newDecoder(br, f.state, f.dict, size, in, f.out)
+	if err != nil {
+		return dst, err
+	}
+	d.trace = f.cfg.Trace
+	buf := bytes.NewBuffer(dst)
+	_, err = buf.ReadFrom(d)
+	dst = buf.Bytes()
+	if err != nil {
+		return dst, err
+	}
+	if br.Len() > 0 {
+		return dst, d.corrupt(ReasonDataAfterEOS, nil)
+	}
+	f.in = in + int64(len(f.frame))
+	f.out += size
+	return dst, nil
+}
@@ -0,0 +1,123 @@
+package lzma
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func writeFrames(t *testing.T, msgs [][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := WriterConfig{}.NewFrameWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewFrameWriter: %v", err)
+	}
+	for _, m := range msgs {
+		if _, err = w.Write(m); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err = w.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFrames(t *testing.T) {
+	var msgs [][]byte
+	for i := 0; i < 20; i++ {
+		msgs = append(msgs, []byte(fmt.Sprintf(
+			`{"id":%d,"user":"alice","action":"update"}`, i)))
+	}
+	msgs = append(msgs, testData(100000))
+	data := writeFrames(t, msgs)
+	r, err := ReaderConfig{}.NewFrameReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewFrameReader: %v", err)
+	}
+	for i, m := range msgs {
+		got, err := r.ReadMessage(nil)
+		if err != nil {
+			t.Fatalf("message %d: ReadMessage: %v", i, err)
+		}
+		if !bytes.Equal(got, m) {
+			t.Fatalf("message %d differs", i)
+		}
+	}
+	if _, err = r.ReadMessage(nil); err != io.EOF {
+		t.Fatalf("ReadMessage after last frame: %v; want io.EOF", err)
+	}
+}
+
+// frame returns the stream header of a default FrameWriter followed by a
+// frame header with the given lengths and the payload.
+func frame(clen, size uint64, payload []byte) []byte {
+	p := []byte{Properties{LC: 3, LP: 0, PB: 2}.ToByte(), 0, 0, 0x80, 0}
+	p = appendUvarint(p, clen)
+	p = appendUvarint(p, size)
+	return append(p, payload...)
+}
+
+func TestFramesMalformed(t *testing.T) {
+	msg := []byte("hello, hello, hello")
+	good := writeFrames(t, [][]byte{msg})
+	// The payload starts after the stream header and one byte for each
+	// length.
+	if !bytes.Equal(good[:7], frame(uint64(len(good)-7), uint64(len(msg)), nil)) {
+		t.Fatalf("unexpected stream or frame header % x", good[:7])
+	}
+	corrupt := append([]byte(nil), good...)
+	corrupt[len(corrupt)-3] ^= 0x55
+	extra := frame(uint64(len(good)-7+1), uint64(len(msg)),
+		append(append([]byte(nil), good[7:]...), 0))
+
+	tests := []struct {
+		name string
+		cfg  ReaderConfig
+		data []byte
+		want interface{}
+	}{
+		{"header", ReaderConfig{}, good[:3], ErrCorruptInput},
+		{"huge length", ReaderConfig{}, frame(1<<60, 10, nil),
+			ErrCorruptInput},
+		{"large length", ReaderConfig{}, frame(1<<34, 10, nil),
+			ErrCorruptInput},
+		{"frame limit", ReaderConfig{MaxFrameSize: 100},
+			frame(1<<34, 10, nil), new(*FrameSizeLimitError)},
+		{"size limit frame", ReaderConfig{MaxSize: 1000},
+			frame(1<<34, 10, nil), new(*FrameSizeLimitError)},
+		{"size limit", ReaderConfig{MaxSize: 10}, good,
+			new(*SizeLimitError)},
+		{"ratio limit", ReaderConfig{MaxRatio: 1}, frame(5, 100, nil),
+			new(*RatioLimitError)},
+		{"dict limit", ReaderConfig{MaxDictCap: 1 << 16}, good,
+			new(*DictCapLimitError)},
+		{"truncated", ReaderConfig{}, good[:len(good)-1],
+			ErrCorruptInput},
+		{"corrupt", ReaderConfig{}, corrupt, ErrCorruptInput},
+		{"extra data", ReaderConfig{}, extra, ErrCorruptInput},
+	}
+	for _, tc := range tests {
+		r, err := tc.cfg.NewFrameReader(bytes.NewReader(tc.data))
+		if err != nil {
+			t.Fatalf("%s: NewFrameReader: %v", tc.name, err)
+		}
+		_, err = r.ReadMessage(nil)
+		switch w := tc.want.(type) {
+		case error:
+			if !errors.Is(err, w) {
+				t.Errorf("%s: got error %v; want %v", tc.name, err, w)
+			}
+		default:
+			if !errors.As(err, w) {
+				t.Errorf("%s: got error %v; want %T", tc.name, err, w)
+			}
+		}
+	}
+}
@@ -2,9 +2,14 @@ package lzma
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 )
 
+// HeaderLen is the length of the header of an LZMA file.
+const HeaderLen = 13
+
 const noHeaderSize uint64 = 1<<64 - 1
 
 type header struct {
@@ -21,17 +26,64 @@ func (h *header) marshalBinary() ([]byte, error) {
 		return nil, fmt.Errorf("lzma: DictCap %d out of range", h.dictCap)
 	}
 
-	data := make([]byte, 13)
+	data := make([]byte, HeaderLen)
 
 	data[0] = h.properties.ToByte()
 
 	binary.LittleEndian.PutUint32(data[1:5], uint32(h.dictCap))
 
 	s := noHeaderSize
-	if h.size > 0 {
+	if h.size >= 0 {
 		s = uint64(h.size)
 	}
 	binary.LittleEndian.PutUint64(data[5:], s)
 
 	return data, nil
 }
+
+func (h *header) unmarshalBinary(data []byte) error {
+	if len(data) != HeaderLen {
+		return errors.New("lzma: wrong header length")
+	}
+	if err := h.properties.fromByte(data[0]); err != nil {
+		return &CorruptInputError{Reason: ReasonBadProperties, Err: err}
+	}
+
+	h.dictCap = int(binary.LittleEndian.Uint32(data[1:5]))
+	if h.dictCap < 0 {
+		return &CorruptInputError{Offset: 1, Reason: ReasonBadHeader,
+			Err: errors.New("DictCap exceeds maximum int value")}
+	}
+
+	s := binary.LittleEndian.Uint64(data[5:])
+	if s == noHeaderSize {
+		h.size = -1
+	} else {
+		h.size = int64(s)
+		if h.size < 0 {
+			return &CorruptInputError{Offset: 5, Reason: ReasonBadHeader,
+				Err: errors.New("size exceeds maximum int64 value")}
+		}
+	}
+	return nil
+}
+
+// readHeader reads the header of an LZMA file. It returns io.EOF if there
+// is no data at all.
+func readHeader(br io.ByteReader) (header, error) {
+	var h header
+	data := make([]byte, HeaderLen)
+	for i := range data {
+		c, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				return h, &CorruptInputError{Offset: int64(i),
+					Reason: ReasonTruncated, Err: io.ErrUnexpectedEOF}
+			}
+			return h, err
+		}
+		data[i] = c
+	}
+	err := h.unmarshalBinary(data)
+	return h, err
+}
@@ -56,3 +56,22 @@ func (lc *lengthCodec) Encode(e *rangeEncoder, l uint32, posState uint32) error
 	}
 	return nil
 }
+
+func (lc *lengthCodec) Decode(d *rangeDecoder, posState uint32) (uint32, error) {
+	b, err := lc.choice[0].Decode(d)
+	if err != nil {
+		return 0, err
+	}
+	if b == 0 {
+		return lc.low[posState].Decode(d)
+	}
+	if b, err = lc.choice[1].Decode(d); err != nil {
+		return 0, err
+	}
+	if b == 0 {
+		n, err := lc.mid[posState].Decode(d)
+		return n + 8, err
+	}
+	n, err := lc.high.Decode(d)
+	return n + 16, err
+}
@@ -0,0 +1,114 @@
+package lzma
+
+import "fmt"
+
+// DictCapLimitError is returned if a header requests a dictionary capacity
+// above ReaderConfig.MaxDictCap.
+type DictCapLimitError struct {
+	DictCap int
+	Limit   int
+}
+
+func (e *DictCapLimitError) Error() string {
+	return fmt.Sprintf("lzma: dictionary capacity %d exceeds limit %d",
+		e.DictCap, e.Limit)
+}
+
+// SizeLimitError is returned if the decompressed data would exceed
+// ReaderConfig.MaxSize.
+type SizeLimitError struct {
+	Limit int64
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("lzma: decompressed size exceeds limit %d", e.Limit)
+}
+
+// RatioLimitError is returned if the ratio of decompressed to compressed
+// bytes exceeds ReaderConfig.MaxRatio.
+type RatioLimitError struct {
+	Compressed   int64
+	Decompressed int64
+	Limit        float64
+}
+
+func (e *RatioLimitError) Error() string {
+	return fmt.Sprintf(
+		"lzma: %d bytes decompressed from %d bytes exceed ratio limit %g",
+		e.Decompressed, e.Compressed, e.Limit)
+}
+
+// FrameSizeLimitError is returned if a frame header announces more
+// compressed bytes than ReaderConfig.MaxFrameSize permits.
+type FrameSizeLimitError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *FrameSizeLimitError) Error() string {
+	return fmt.Sprintf("lzma: frame size %d exceeds limit %d",
+		e.Size, e.Limit)
+}
+
+// limits enforces the size and ratio limits for a decoder.
+type limits struct {
+	maxSize  int64
+	maxRatio float64
+}
+
+// newLimits returns the limits of the configuration or nil if there are
+// none.
+func (c *ReaderConfig) newLimits() *limits {
+	if c.MaxSize == 0 && c.MaxRatio == 0 {
+		return nil
+	}
+	return &limits{maxSize: c.MaxSize, maxRatio: c.MaxRatio}
+}
+
+// checkDictCap verifies the dictionary capacity requested by a header.
+func (c *ReaderConfig) checkDictCap(dictCap int) error {
+	if c.MaxDictCap > 0 && dictCap > c.MaxDictCap {
+		return &DictCapLimitError{DictCap: dictCap, Limit: c.MaxDictCap}
+	}
+	return nil
+}
+
+// outputLimit returns the maximum number of bytes that may be decompressed
+// from n bytes of input or -1 if there is no limit.
+func (c *ReaderConfig) outputLimit(n int) int64 {
+	m := int64(-1)
+	if c.MaxSize > 0 {
+		m = c.MaxSize
+	}
+	if c.MaxRatio > 0 {
+		r := c.MaxRatio * float64(n)
+		if r < float64(maxInt64) && (m < 0 || int64(r) < m) {
+			m = int64(r)
+		}
+	}
+	return m
+}
+
+// checkSize verifies a decompressed size, which may be provided by a
+// header.
+func (c *ReaderConfig) checkSize(size int64) error {
+	if c.MaxSize > 0 && size > c.MaxSize {
+		return &SizeLimitError{Limit: c.MaxSize}
+	}
+	return nil
+}
+
+func (l *limits) check(d *decoder) error {
+	out := d.out + d.Decompressed()
+	if l.maxSize > 0 && out > l.maxSize {
+		return &SizeLimitError{Limit: l.maxSize}
+	}
+	if l.maxRatio > 0 {
+		in := d.in + d.rd.read
+		if float64(out) > l.maxRatio*float64(in) {
+			return &RatioLimitError{Compressed: in, Decompressed: out,
+				Limit: l.maxRatio}
+		}
+	}
+	return nil
+}
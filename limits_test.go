@@ -0,0 +1,46 @@
+package lzma
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMaxDictCap(t *testing.T) {
+	data := testData(1000)
+	lzma := compressWith(t, WriterConfig{DictCap: 1 << 20}, data)
+	lz := lzipCompress(t, LzipWriterConfig{DictCap: 1 << 20}, data)
+	cfg := ReaderConfig{MaxDictCap: 1 << 16}
+	decoders := []struct {
+		name   string
+		decode func(cfg ReaderConfig) error
+	}{
+		{"NewReader", func(cfg ReaderConfig) error {
+			_, err := cfg.NewReader(bytes.NewReader(lzma))
+			return err
+		}},
+		{"Decompress", func(cfg ReaderConfig) error {
+			_, err := cfg.Decompress(nil, lzma)
+			return err
+		}},
+		{"NewLzipReader", func(cfg ReaderConfig) error {
+			_, err := lzipDecompress(cfg, lz)
+			return err
+		}},
+	}
+	for _, d := range decoders {
+		err := d.decode(cfg)
+		var e *DictCapLimitError
+		if !errors.As(err, &e) {
+			t.Errorf("%s: got error %v; want DictCapLimitError",
+				d.name, err)
+			continue
+		}
+		if e.DictCap != 1<<20 || e.Limit != cfg.MaxDictCap {
+			t.Errorf("%s: got %+v", d.name, e)
+		}
+		if err = d.decode(ReaderConfig{MaxDictCap: 1 << 20}); err != nil {
+			t.Errorf("%s: %v", d.name, err)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package lzma
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// lzip member layout: a header of 6 bytes, the raw LZMA stream terminated
+// by an EOS marker and a trailer of 20 bytes.
+const (
+	lzipHeaderLen  = 6
+	lzipTrailerLen = 20
+	lzipVersion    = 1
+)
+
+// lzipMagic starts every lzip member.
+var lzipMagic = []byte("LZIP")
+
+// lzipProperties are the fixed literal and position parameters used by the
+// lzip format.
+var lzipProperties = Properties{LC: 3, LP: 0, PB: 2}
+
+// MinLzipDictCap and MaxLzipDictCap provide the range of dictionary
+// capacities supported by the lzip format.
+const (
+	MinLzipDictCap = 1 << 12
+	MaxLzipDictCap = 1 << 29
+)
+
+// MinLzipMemberSize and MaxLzipMemberSize limit the member size that can be
+// requested from the lzip writer.
+const (
+	MinLzipMemberSize = 1 << 12
+	MaxLzipMemberSize = 1 << 51
+)
+
+var (
+	errLzipMagic   = errors.New("lzip magic not found")
+	errLzipVersion = errors.New("unsupported lzip version")
+	errLzipCRC     = errors.New("lzip CRC32 mismatch")
+	errLzipSize    = errors.New("lzip data size mismatch")
+	errLzipMember  = errors.New("lzip member size mismatch")
+)
+
+// lzipDictCap decodes the coded dictionary size byte of the lzip header.
+func lzipDictCap(b byte) (int, error) {
+	e := uint(b & 0x1f)
+	if e < 12 || e > 29 {
+		return 0, fmt.Errorf("lzip dictionary size exponent %d out of range", e)
+	}
+	n := 1 << e
+	n -= (n >> 4) * int(b>>5)
+	if n < MinLzipDictCap {
+		return 0, errors.New("lzip dictionary size too small")
+	}
+	return n, nil
+}
+
+// lzipDictByte returns the coded dictionary size byte for the smallest
+// dictionary size that is at least n.
+func lzipDictByte(n int) byte {
+	e := uint(12)
+	for 1<<e < n {
+		e++
+	}
+	for frac := 7; frac > 0; frac-- {
+		if 1<<e-frac*(1<<e>>4) >= n {
+			return byte(e) | byte(frac<<5)
+		}
+	}
+	return byte(e)
+}
+
+// crcMatcher computes the CRC32 of all data that has been encoded, which is
+// exactly the data written into the matcher.
+type crcMatcher struct {
+	matcher
+	crc uint32
+}
+
+func (m *crcMatcher) Write(p []byte) (int, error) {
+	m.crc = crc32.Update(m.crc, crc32.IEEETable, p)
+	return m.matcher.Write(p)
+}
+
+// LzipWriterConfig defines the parameters for the lzip writer. A
+// MemberSize of zero doesn't limit the size of the members.
+type LzipWriterConfig struct {
+	DictCap    int
+	BufSize    int
+	Matcher    MatchAlgorithm
+	MemberSize int64
+}
+
+// LzipWriter compresses data into the lzip format.
+type LzipWriter struct {
+	cfg     LzipWriterConfig
+	bw      io.ByteWriter
+	buf     *bufio.Writer
+	lbw     *LimitedByteWriter
+	limit   int64
+	m       *crcMatcher
+	e       *encoder
+	dictCap int
+}
+
+func NewLzipWriter(lz io.Writer) (*LzipWriter, error) {
+	return LzipWriterConfig{}.NewWriter(lz)
+}
+
+func (c *LzipWriterConfig) fill() {
+	if c.DictCap == 0 {
+		c.DictCap = 8 * 1024 * 1024
+	}
+	if c.BufSize == 0 {
+		c.BufSize = 4096
+	}
+}
+
+func (c *LzipWriterConfig) Verify() error {
+	if c == nil {
+		return &ConfigError{"LzipWriterConfig", "LzipWriterConfig is nil"}
+	}
+	c.fill()
+	if c.DictCap < MinLzipDictCap || c.DictCap > MaxLzipDictCap {
+		return &ConfigError{"DictCap",
+			"lzip dictionary capacity is out of range"}
+	}
+	if c.BufSize < maxMatchLen {
+		return &ConfigError{"BufSize", "lookahead buffer size too small"}
+	}
+	if c.MemberSize != 0 &&
+		(c.MemberSize < MinLzipMemberSize || c.MemberSize > MaxLzipMemberSize) {
+		return &ConfigError{"MemberSize", "lzip member size is out of range"}
+	}
+	return c.Matcher.verify()
+}
+
+func (c LzipWriterConfig) NewWriter(lz io.Writer) (*LzipWriter, error) {
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	w := &LzipWriter{cfg: c}
+	w.dictCap, _ = lzipDictCap(lzipDictByte(c.DictCap))
+	var ok bool
+	w.bw, ok = lz.(io.ByteWriter)
+	if !ok {
+		w.buf = bufio.NewWriter(lz)
+		w.bw = w.buf
+	}
+	if err := w.startMember(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// startMember writes the lzip header and creates a fresh encoder for the
+// next member.
+func (w *LzipWriter) startMember() error {
+	hdr := make([]byte, 0, lzipHeaderLen)
+	hdr = append(hdr, lzipMagic...)
+	hdr = append(hdr, lzipVersion, lzipDictByte(w.dictCap))
+	if _, err := w.bw.(io.Writer).Write(hdr); err != nil {
+		return err
+	}
+
+	w.limit = maxInt64
+	if w.cfg.MemberSize > 0 {
+		w.limit = w.cfg.MemberSize - lzipHeaderLen - lzipTrailerLen
+	}
+	w.lbw = &LimitedByteWriter{BW: w.bw, N: w.limit}
+	m, err := w.cfg.Matcher.new(w.dictCap)
+	if err != nil {
+		return err
+	}
+	w.m = &crcMatcher{matcher: m}
+	dict, err := newEncoderDict(w.dictCap, w.cfg.BufSize, w.m)
+	if err != nil {
+		return err
+	}
+	w.e, err = newEncoder(w.lbw, newState(lzipProperties), dict, eosMarker)
+	return err
+}
+
+// finishMember terminates the current member and writes its trailer. The
+// data that has been buffered but not encoded is returned.
+func (w *LzipWriter) finishMember() ([]byte, error) {
+	d := w.e.dict
+	rest := make([]byte, d.Buffered())
+	d.buf.Peek(rest)
+	if err := w.e.Close(); err != nil {
+		return nil, err
+	}
+	size := w.e.Compressed()
+	rest = rest[len(rest)-d.Buffered():]
+
+	var trailer [lzipTrailerLen]byte
+	binary.LittleEndian.PutUint32(trailer[0:], w.m.crc)
+	binary.LittleEndian.PutUint64(trailer[4:], uint64(size))
+	n := lzipHeaderLen + (w.limit - w.lbw.N) + lzipTrailerLen
+	binary.LittleEndian.PutUint64(trailer[12:], uint64(n))
+	_, err := w.bw.(io.Writer).Write(trailer[:])
+	return rest, err
+}
+
+func (w *LzipWriter) Write(p []byte) (n int, err error) {
+	for {
+		k, err := w.e.Write(p[n:])
+		n += k
+		if err != ErrLimit {
+			return n, err
+		}
+		rest, err := w.finishMember()
+		if err != nil {
+			return n, err
+		}
+		if err = w.startMember(); err != nil {
+			return n, err
+		}
+		if _, err = w.e.Write(rest); err != nil {
+			return n, err
+		}
+	}
+}
+
+// Close finishes the last member. It doesn't close the underlying writer.
+func (w *LzipWriter) Close() error {
+	for {
+		rest, err := w.finishMember()
+		if err != nil {
+			return err
+		}
+		if len(rest) == 0 {
+			break
+		}
+		if err = w.startMember(); err != nil {
+			return err
+		}
+		if _, err = w.e.Write(rest); err != nil {
+			return err
+		}
+	}
+	if w.buf != nil {
+		return w.buf.Flush()
+	}
+	return nil
+}
+
+// LzipReader decompresses all members of an lzip file.
+type LzipReader struct {
+	cfg ReaderConfig
+	br  *countingByteReader
+	d   *decoder
+	crc uint32
+	eof bool
+	err error
+	// compressed and decompressed bytes of the previous members
+	in, out int64
+}
+
+func NewLzipReader(lz io.Reader) (*LzipReader, error) {
+	return ReaderConfig{}.NewLzipReader(lz)
+}
+
+// NewLzipReader creates a reader for lzip files. The limits of the
+// configuration apply to the whole file. The field DictCap is ignored
+// because lzip headers provide the exact dictionary size.
+func (c ReaderConfig) NewLzipReader(lz io.Reader) (*LzipReader, error) {
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	r := &LzipReader{cfg: c, br: &countingByteReader{br: byteReader(lz)}}
+	if err := r.startMember(); err != nil {
+		if err == io.EOF {
+			return nil, r.corrupt(ReasonBadHeader, 0, errLzipMagic)
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// corrupt returns the error for corrupt input detected at offset off of the
+// current member.
+func (r *LzipReader) corrupt(reason CorruptReason, off int64, err error) error {
+	pos := r.out
+	if r.d != nil {
+		pos += r.d.Decompressed()
+	}
+	return &CorruptInputError{
+		Offset: r.in + off,
+		Pos:    pos,
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+// startMember reads the header of the next member. It returns io.EOF if
+// there is no further member. The dictionary of the previous member is
+// reused if it is large enough.
+func (r *LzipReader) startMember() error {
+	var hdr [lzipHeaderLen]byte
+	var prev *decoderDict
+	if r.d != nil {
+		prev = r.d.dict
+	}
+	r.d = nil
+	for i := range hdr {
+		c, err := r.br.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				return r.corrupt(ReasonTruncated, int64(i),
+					io.ErrUnexpectedEOF)
+			}
+			return err
+		}
+		hdr[i] = c
+	}
+	if !bytes.Equal(hdr[:4], lzipMagic) {
+		return r.corrupt(ReasonBadHeader, 0, errLzipMagic)
+	}
+	if hdr[4] != lzipVersion {
+		return r.corrupt(ReasonBadHeader, 4, errLzipVersion)
+	}
+	dictCap, err := lzipDictCap(hdr[5])
+	if err != nil {
+		return r.corrupt(ReasonBadHeader, 5, err)
+	}
+	if err = r.cfg.checkDictCap(dictCap); err != nil {
+		return err
+	}
+	var dict *decoderDict
+	if prev != nil && prev.capacity >= dictCap {
+		dict = prev
+		dict.Reset()
+	} else if dict, err = newDecoderDict(dictCap); err != nil {
+		return err
+	}
+	r.br.n = lzipHeaderLen
+	r.crc = 0
+	r.d, err = newDecoder(r.br, newState(lzipProperties), dict, -1,
+		r.in+lzipHeaderLen, r.out)
+	if err != nil {
+		return err
+	}
+	r.d.trace = r.cfg.Trace
+	r.d.lim = r.cfg.newLimits()
+	r.d.salvage = r.cfg.Salvage
+	return nil
+}
+
+// nextMember starts the member following the current one. It returns
+// io.EOF if there is no further member or a single member has been
+// requested.
+func (r *LzipReader) nextMember() error {
+	if r.cfg.SingleStream {
+		return io.EOF
+	}
+	if err := r.startMember(); err != nil {
+		return trailingData(err, r.in, r.out)
+	}
+	return nil
+}
+
+// finishMember reads and checks the trailer of the current member.
+func (r *LzipReader) finishMember() error {
+	if !r.d.eosMarker {
+		return r.corrupt(ReasonMissingEOS, r.br.n, nil)
+	}
+	var trailer [lzipTrailerLen]byte
+	start := r.br.n
+	for i := range trailer {
+		c, err := r.br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return r.corrupt(ReasonTruncated, r.br.n,
+					io.ErrUnexpectedEOF)
+			}
+			return err
+		}
+		trailer[i] = c
+	}
+	if binary.LittleEndian.Uint32(trailer[0:]) != r.crc {
+		return r.corrupt(ReasonChecksum, start, errLzipCRC)
+	}
+	if binary.LittleEndian.Uint64(trailer[4:]) != uint64(r.d.Decompressed()) {
+		return r.corrupt(ReasonSizeMismatch, start+4, errLzipSize)
+	}
+	if binary.LittleEndian.Uint64(trailer[12:]) != uint64(r.br.n) {
+		return r.corrupt(ReasonSizeMismatch, start+12, errLzipMember)
+	}
+	r.in += r.br.n
+	r.out += r.d.Decompressed()
+	return nil
+}
+
+func (r *LzipReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	defer func() {
+		if err != nil && err != io.EOF {
+			r.err = err
+		}
+	}()
+	for n < len(p) && !r.eof {
+		var k int
+		k, err = r.d.Read(p[n:])
+		r.crc = crc32.Update(r.crc, crc32.IEEETable, p[n:n+k])
+		n += k
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			return n, err
+		}
+		if err = r.finishMember(); err != nil {
+			return n, err
+		}
+		if err = r.nextMember(); err != nil {
+			if err != io.EOF {
+				return n, err
+			}
+			r.eof = true
+		}
+	}
+	if r.eof {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// countingByteReader counts the bytes read from the underlying reader.
+type countingByteReader struct {
+	br io.ByteReader
+	n  int64
+}
+
+func (r *countingByteReader) ReadByte() (byte, error) {
+	c, err := r.br.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return c, err
+}
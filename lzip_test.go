@@ -0,0 +1,185 @@
+package lzma
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func lzipCompress(t *testing.T, cfg LzipWriterConfig, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := cfg.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func lzipDecompress(cfg ReaderConfig, lz []byte) ([]byte, error) {
+	r, err := cfg.NewLzipReader(bytes.NewReader(lz))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// lzipMembers returns the sizes of the members of an lzip file by
+// following the member sizes stored in the trailers from the end.
+func lzipMembers(t *testing.T, lz []byte) []int {
+	t.Helper()
+	var sizes []int
+	for n := len(lz); n > 0; {
+		if n < lzipHeaderLen+lzipTrailerLen {
+			t.Fatalf("%d bytes left before member", n)
+		}
+		k := int(binary.LittleEndian.Uint64(lz[n-8:]))
+		if k > n || !bytes.HasPrefix(lz[n-k:], lzipMagic) {
+			t.Fatalf("no member of size %d before offset %d", k, n)
+		}
+		sizes = append([]int{k}, sizes...)
+		n -= k
+	}
+	return sizes
+}
+
+func TestLzip(t *testing.T) {
+	for _, n := range []int{0, 1, 100000} {
+		data := testData(n)
+		lz := lzipCompress(t, LzipWriterConfig{}, data)
+		if k := len(lzipMembers(t, lz)); k != 1 {
+			t.Fatalf("n=%d: %d members; want 1", n, k)
+		}
+		got, err := lzipDecompress(ReaderConfig{}, lz)
+		if err != nil {
+			t.Fatalf("n=%d: decoding: %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("n=%d: decoded data differs", n)
+		}
+	}
+}
+
+func TestLzipMembers(t *testing.T) {
+	data := make([]byte, 60000)
+	rand.New(rand.NewSource(2)).Read(data[20000:40000])
+	copy(data, testData(20000))
+	const memberSize = 8192
+	lz := lzipCompress(t, LzipWriterConfig{MemberSize: memberSize}, data)
+	sizes := lzipMembers(t, lz)
+	if len(sizes) < 3 {
+		t.Fatalf("%d members; want at least 3", len(sizes))
+	}
+	for i, k := range sizes {
+		if k > memberSize {
+			t.Errorf("member %d has size %d above limit %d",
+				i, k, memberSize)
+		}
+	}
+	got, err := lzipDecompress(ReaderConfig{}, lz)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decoded data differs")
+	}
+	got, err = lzipDecompress(ReaderConfig{SingleStream: true}, lz)
+	if err != nil {
+		t.Fatalf("decoding first member: %v", err)
+	}
+	if !bytes.Equal(got, data[:len(got)]) || len(got) == len(data) {
+		t.Fatalf("first member decoded incorrectly")
+	}
+}
+
+func TestLzipMemberDict(t *testing.T) {
+	data := make([]byte, 60000)
+	rand.New(rand.NewSource(6)).Read(data)
+	cfg := LzipWriterConfig{DictCap: 1 << 20, MemberSize: 8192}
+	lz := lzipCompress(t, cfg, data)
+	members := len(lzipMembers(t, lz))
+	if members < 5 {
+		t.Fatalf("%d members; want at least 5", members)
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	before := ms.TotalAlloc
+	got, err := lzipDecompress(ReaderConfig{}, lz)
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	runtime.ReadMemStats(&ms)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decoded data differs")
+	}
+	// The dictionary of the first member is used for all members.
+	if n := ms.TotalAlloc - before; n > 2*uint64(cfg.DictCap) {
+		t.Errorf("decoding %d members allocates %d bytes", members, n)
+	}
+}
+
+func TestLzipTrailer(t *testing.T) {
+	data := testData(10000)
+	lz := lzipCompress(t, LzipWriterConfig{}, data)
+	trailer := len(lz) - lzipTrailerLen
+	modify := func(i int) []byte {
+		p := append([]byte(nil), lz...)
+		p[i] ^= 1
+		return p
+	}
+	tests := []struct {
+		name   string
+		lz     []byte
+		reason CorruptReason
+	}{
+		{"crc", modify(trailer), ReasonChecksum},
+		{"data size", modify(trailer + 4), ReasonSizeMismatch},
+		{"member size", modify(trailer + 12), ReasonSizeMismatch},
+		{"truncated trailer", lz[:len(lz)-1], ReasonTruncated},
+		{"trailing data", append(append([]byte(nil), lz...), "LZIPgarbage"...),
+			ReasonTrailingData},
+		{"version", modify(4), ReasonBadHeader},
+	}
+	for _, tc := range tests {
+		_, err := lzipDecompress(ReaderConfig{}, tc.lz)
+		var e *CorruptInputError
+		if !errors.As(err, &e) || e.Reason != tc.reason {
+			t.Errorf("%s: got error %v; want %v", tc.name, err, tc.reason)
+		}
+	}
+}
+
+func TestLzipXZ(t *testing.T) {
+	path, err := exec.LookPath("xz")
+	if err != nil {
+		t.Skip("xz not installed")
+	}
+	data := testData(100000)
+	lz := lzipCompress(t, LzipWriterConfig{MemberSize: 16384}, data)
+	cmd := exec.Command(path, "--format=lzip", "-dc")
+	cmd.Stdin = bytes.NewReader(lz)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	got, err := cmd.Output()
+	if err != nil {
+		// xz supports lzip since version 5.4.
+		if bytes.Contains(stderr.Bytes(), []byte("format")) {
+			t.Skipf("xz doesn't support lzip: %s", stderr.Bytes())
+		}
+		t.Fatalf("xz: %v: %s", err, stderr.Bytes())
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("xz output differs from input")
+	}
+}
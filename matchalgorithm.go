@@ -1,7 +1,5 @@
 package lzma
 
-import "errors"
-
 type MatchAlgorithm byte
 
 const (
@@ -21,7 +19,7 @@ func (a MatchAlgorithm) String() string {
 	return "unknown"
 }
 
-var errUnsupportedMatchAlgorithm = errors.New("lzma: unsupported match algorithm value")
+var errUnsupportedMatchAlgorithm = &ConfigError{"Matcher", "unsupported match algorithm value"}
 
 func (a MatchAlgorithm) verify() error {
 	if _, ok := maStrings[a]; !ok {
@@ -0,0 +1,111 @@
+package lzma
+
+import (
+	"unsafe"
+
+	"mylzma/internal/hash"
+)
+
+// The memory usage functions mirror the allocations of the constructors
+// and return the number of bytes requested from the Go runtime.
+
+// bufioSize is the size of the buffers of bufio.Reader and bufio.Writer.
+const bufioSize = 4096
+
+func probsMemory(n int) int64 {
+	return int64(n) * int64(unsafe.Sizeof(prob(0)))
+}
+
+func treeMemory(bits int) int64 {
+	return probsMemory(1 << uint(bits))
+}
+
+func lengthCodecMemory() int64 {
+	n := 2 * (1 << maxPosBits) * treeMemory(3)
+	return n + treeMemory(8)
+}
+
+func distCodecMemory() int64 {
+	n := lenStates * treeMemory(posSlotBits)
+	for posSlot := startPosModel; posSlot < endPosModel; posSlot++ {
+		n += treeMemory((posSlot >> 1) - 1)
+	}
+	return n + treeMemory(alignBits)
+}
+
+// stateMemory returns the memory used by the probability model.
+func stateMemory(p Properties) int64 {
+	n := int64(unsafe.Sizeof(state{}))
+	n += probsMemory(0x300 << uint(p.LC+p.LP))
+	n += 2 * lengthCodecMemory()
+	return n + distCodecMemory()
+}
+
+// memory returns the memory used by the matcher for the given dictionary
+// capacity.
+func (a MatchAlgorithm) memory(dictCap int) int64 {
+	switch a {
+	case HashTable4:
+		n := int64(unsafe.Sizeof(hashTable{}))
+		n += int64(unsafe.Sizeof(int64(0))) << uint(hashTableExponent(uint32(dictCap)))
+		n += int64(dictCap) * int64(unsafe.Sizeof(uint32(0)))
+		r := int64(unsafe.Sizeof(hash.CyclicPoly{})) +
+			4*int64(unsafe.Sizeof(uint64(0)))
+		return n + 2*r
+	case BinaryTree:
+		n := int64(unsafe.Sizeof(binTree{}))
+		n += int64(dictCap) * int64(unsafe.Sizeof(node{}))
+		return n + maxMatchLen
+	}
+	return 0
+}
+
+// MemoryUsage returns the number of bytes allocated by a Writer created
+// with this configuration. It includes the buffer that is used if the
+// underlying writer doesn't support io.ByteWriter, the block buffer for
+// EntropyThreshold and the sample collected for AutoProperties.
+func (c WriterConfig) MemoryUsage() (int64, error) {
+	if err := c.Verify(); err != nil {
+		return 0, err
+	}
+	n := int64(unsafe.Sizeof(Writer{}) + unsafe.Sizeof(encoder{}) +
+		unsafe.Sizeof(rangeEncoder{}) + unsafe.Sizeof(LimitedByteWriter{}))
+	n += bufioSize
+	n += int64(unsafe.Sizeof(encoderDict{})) + int64(c.DictCap+c.BufSize+1)
+	n += stateMemory(*c.Properties)
+	if c.EntropyThreshold > 0 {
+		n += entropyBlockLen
+	}
+	if c.AutoProperties {
+		n += autoSampleLen
+	}
+	return n + c.Matcher.memory(c.DictCap), nil
+}
+
+// MemoryUsage returns the number of bytes allocated by a Reader created
+// with this configuration for an LZMA file starting with the given
+// header. It includes the buffer that is used if the underlying reader
+// doesn't support io.ByteReader.
+func (c ReaderConfig) MemoryUsage(hdr []byte) (int64, error) {
+	if err := c.Verify(); err != nil {
+		return 0, err
+	}
+	var h header
+	if err := h.unmarshalBinary(hdr); err != nil {
+		return 0, err
+	}
+	// Verify has set DictCap to its default of 8 MiB if it was zero. As
+	// in startStream, it is the minimum capacity of the dictionary.
+	dictCap := h.dictCap
+	if dictCap < MinDictCap {
+		dictCap = MinDictCap
+	}
+	if c.DictCap > dictCap {
+		dictCap = c.DictCap
+	}
+	n := int64(unsafe.Sizeof(Reader{}) + unsafe.Sizeof(decoder{}) +
+		unsafe.Sizeof(rangeDecoder{}))
+	n += bufioSize + HeaderLen
+	n += int64(unsafe.Sizeof(decoderDict{})) + int64(dictCap+1)
+	return n + stateMemory(h.properties), nil
+}
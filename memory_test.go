@@ -0,0 +1,119 @@
+package lzma
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+)
+
+// liveHeap returns the number of bytes of live heap objects.
+func liveHeap() int64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapAlloc)
+}
+
+// checkMemory compares an estimate with the measured memory usage. Small
+// allocations not covered by the estimates and the rounding of allocation
+// sizes are tolerated.
+func checkMemory(t *testing.T, name string, estimate, used int64) {
+	t.Helper()
+	d := estimate - used
+	if d < 0 {
+		d = -d
+	}
+	if d > used/50+12<<10 {
+		t.Errorf("%s: estimate %d; measured %d", name, estimate, used)
+	}
+}
+
+func TestWriterMemoryUsage(t *testing.T) {
+	data := testData(20000)
+	tests := []struct {
+		name string
+		cfg  WriterConfig
+	}{
+		{"default", WriterConfig{}},
+		{"BinaryTree", WriterConfig{Matcher: BinaryTree, DictCap: 1 << 20}},
+		{"EntropyThreshold", WriterConfig{DictCap: 1 << 16,
+			EntropyThreshold: 7}},
+		{"AutoProperties", WriterConfig{DictCap: 1 << 16,
+			AutoProperties: true}},
+	}
+	for _, tc := range tests {
+		estimate, err := tc.cfg.MemoryUsage()
+		if err != nil {
+			t.Fatalf("%s: MemoryUsage: %v", tc.name, err)
+		}
+		// The encoder replaces the sample of AutoProperties, so the
+		// data written must not complete the sample for the estimate
+		// to be reached.
+		var sample int64
+		if tc.cfg.AutoProperties {
+			sample = autoSampleLen
+			plain := tc.cfg
+			plain.AutoProperties = false
+			n, err := plain.MemoryUsage()
+			if err != nil {
+				t.Fatalf("%s: MemoryUsage: %v", tc.name, err)
+			}
+			if estimate != n+sample {
+				t.Errorf("%s: estimate %d doesn't include the "+
+					"sample", tc.name, estimate)
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		buf.Grow(len(data))
+		before := liveHeap()
+		w, err := tc.cfg.NewWriter(buf)
+		if err != nil {
+			t.Fatalf("%s: NewWriter: %v", tc.name, err)
+		}
+		if _, err = w.Write(data); err != nil {
+			t.Fatalf("%s: Write: %v", tc.name, err)
+		}
+		used := liveHeap() - before
+		if tc.cfg.AutoProperties {
+			// only the sample is allocated yet
+			checkMemory(t, tc.name, sample, used)
+		} else {
+			// the writer to buf doesn't need a bufio.Writer
+			checkMemory(t, tc.name, estimate-bufioSize, used)
+		}
+		runtime.KeepAlive(w)
+	}
+}
+
+func TestReaderMemoryUsage(t *testing.T) {
+	data := testData(20000)
+	tests := []struct {
+		name    string
+		cfg     ReaderConfig
+		dictCap int
+	}{
+		{"default", ReaderConfig{}, 1 << 16},
+		{"DictCap", ReaderConfig{DictCap: 1 << 16}, 1 << 16},
+		{"header", ReaderConfig{DictCap: 1 << 16}, 12 << 20},
+	}
+	for _, tc := range tests {
+		lzma := compressWith(t, WriterConfig{DictCap: tc.dictCap}, data)
+		estimate, err := tc.cfg.MemoryUsage(lzma[:HeaderLen])
+		if err != nil {
+			t.Fatalf("%s: MemoryUsage: %v", tc.name, err)
+		}
+		before := liveHeap()
+		r, err := tc.cfg.NewReader(bytes.NewReader(lzma))
+		if err != nil {
+			t.Fatalf("%s: NewReader: %v", tc.name, err)
+		}
+		if _, err = r.Read(make([]byte, 100)); err != nil {
+			t.Fatalf("%s: Read: %v", tc.name, err)
+		}
+		used := liveHeap() - before
+		// bytes.Reader is an io.ByteReader
+		checkMemory(t, tc.name, estimate-bufioSize, used)
+		runtime.KeepAlive(r)
+	}
+}
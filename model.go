@@ -0,0 +1,356 @@
+package lzma
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ProbGroup is a group of adaptive probabilities of the LZMA model. The
+// context identifies the group within the model, for instance the state
+// and the posState for the isMatch probabilities. Index describes the
+// meaning of an index into Probs.
+type ProbGroup struct {
+	Name    string         `json:"name"`
+	Context map[string]int `json:"context,omitempty"`
+	Index   string         `json:"index"`
+	Probs   []uint16       `json:"probs"`
+}
+
+// ModelSnapshot is a copy of the complete probability model of an encoder
+// or decoder. It can be serialized with the encoding/json package.
+type ModelSnapshot struct {
+	Properties Properties  `json:"properties"`
+	State      int         `json:"state"`
+	Rep        [4]uint32   `json:"rep"`
+	Groups     []ProbGroup `json:"groups"`
+}
+
+func probValues(p []prob) []uint16 {
+	v := make([]uint16, len(p))
+	for i, x := range p {
+		v[i] = uint16(x)
+	}
+	return v
+}
+
+func lengthGroups(name string, lc *lengthCodec, posStates int) []ProbGroup {
+	g := []ProbGroup{{
+		Name:  name + "Choice",
+		Index: "choice",
+		Probs: probValues(lc.choice[:]),
+	}}
+	for i := 0; i < posStates; i++ {
+		g = append(g, ProbGroup{
+			Name:    name + "Low",
+			Context: map[string]int{"posState": i},
+			Index:   "tree",
+			Probs:   probValues(lc.low[i].probs),
+		})
+	}
+	for i := 0; i < posStates; i++ {
+		g = append(g, ProbGroup{
+			Name:    name + "Mid",
+			Context: map[string]int{"posState": i},
+			Index:   "tree",
+			Probs:   probValues(lc.mid[i].probs),
+		})
+	}
+	return append(g, ProbGroup{
+		Name:  name + "High",
+		Index: "tree",
+		Probs: probValues(lc.high.probs),
+	})
+}
+
+// snapshot copies the model of the state.
+func (s *state) snapshot() *ModelSnapshot {
+	m := &ModelSnapshot{
+		Properties: s.Properties,
+		State:      int(s.state),
+		Rep:        s.rep,
+	}
+	posStates := 1 << uint(s.Properties.PB)
+	for i := 0; i < states; i++ {
+		k := i << maxPosBits
+		m.Groups = append(m.Groups, ProbGroup{
+			Name:    "isMatch",
+			Context: map[string]int{"state": i},
+			Index:   "posState",
+			Probs:   probValues(s.isMatch[k : k+posStates]),
+		})
+	}
+	for i := 0; i < states; i++ {
+		k := i << maxPosBits
+		m.Groups = append(m.Groups, ProbGroup{
+			Name:    "isRepG0Long",
+			Context: map[string]int{"state": i},
+			Index:   "posState",
+			Probs:   probValues(s.isRepG0Long[k : k+posStates]),
+		})
+	}
+	m.Groups = append(m.Groups,
+		ProbGroup{Name: "isRep", Index: "state", Probs: probValues(s.isRep[:])},
+		ProbGroup{Name: "isRepG0", Index: "state", Probs: probValues(s.isRepG0[:])},
+		ProbGroup{Name: "isRepG1", Index: "state", Probs: probValues(s.isRepG1[:])},
+		ProbGroup{Name: "isRepG2", Index: "state", Probs: probValues(s.isRepG2[:])},
+	)
+	for i := 0; i < len(s.litCodec.probs)/0x300; i++ {
+		k := i * 0x300
+		m.Groups = append(m.Groups, ProbGroup{
+			Name:    "literal",
+			Context: map[string]int{"litState": i},
+			Index:   "symbol",
+			Probs:   probValues(s.litCodec.probs[k : k+0x300]),
+		})
+	}
+	m.Groups = append(m.Groups, lengthGroups("len", &s.lenCodec, posStates)...)
+	m.Groups = append(m.Groups,
+		lengthGroups("repLen", &s.repLenCodec, posStates)...)
+	for i := range s.distCodec.posSlotCodecs {
+		m.Groups = append(m.Groups, ProbGroup{
+			Name:    "posSlot",
+			Context: map[string]int{"lenState": i},
+			Index:   "tree",
+			Probs:   probValues(s.distCodec.posSlotCodecs[i].probs),
+		})
+	}
+	for i := range s.distCodec.posModel {
+		m.Groups = append(m.Groups, ProbGroup{
+			Name:    "posModel",
+			Context: map[string]int{"posSlot": startPosModel + i},
+			Index:   "tree",
+			Probs:   probValues(s.distCodec.posModel[i].probs),
+		})
+	}
+	m.Groups = append(m.Groups, ProbGroup{
+		Name:  "align",
+		Index: "tree",
+		Probs: probValues(s.distCodec.alignCodec.probs),
+	})
+	return m
+}
+
+func lengthSlices(lc *lengthCodec, posStates int) [][]prob {
+	p := [][]prob{lc.choice[:]}
+	for i := 0; i < posStates; i++ {
+		p = append(p, lc.low[i].probs)
+	}
+	for i := 0; i < posStates; i++ {
+		p = append(p, lc.mid[i].probs)
+	}
+	return append(p, lc.high.probs)
+}
+
+// probSlices returns the probability arrays of the state in the order of
+// the groups of a snapshot.
+func (s *state) probSlices() [][]prob {
+	var p [][]prob
+	posStates := 1 << uint(s.Properties.PB)
+	for i := 0; i < states; i++ {
+		k := i << maxPosBits
+		p = append(p, s.isMatch[k:k+posStates])
+	}
+	for i := 0; i < states; i++ {
+		k := i << maxPosBits
+		p = append(p, s.isRepG0Long[k:k+posStates])
+	}
+	p = append(p, s.isRep[:], s.isRepG0[:], s.isRepG1[:], s.isRepG2[:])
+	for k := 0; k < len(s.litCodec.probs); k += 0x300 {
+		p = append(p, s.litCodec.probs[k:k+0x300])
+	}
+	p = append(p, lengthSlices(&s.lenCodec, posStates)...)
+	p = append(p, lengthSlices(&s.repLenCodec, posStates)...)
+	for i := range s.distCodec.posSlotCodecs {
+		p = append(p, s.distCodec.posSlotCodecs[i].probs)
+	}
+	for i := range s.distCodec.posModel {
+		p = append(p, s.distCodec.posModel[i].probs)
+	}
+	return append(p, s.distCodec.alignCodec.probs)
+}
+
+var errModelProperties = errors.New(
+	"lzma: model properties don't match the stream properties")
+
+// restore replaces the model of the state by the snapshot m.
+func (s *state) restore(m *ModelSnapshot) error {
+	if m.Properties != s.Properties {
+		return errModelProperties
+	}
+	if m.State < 0 || m.State >= states {
+		return fmt.Errorf("lzma: model state %d out of range", m.State)
+	}
+	p := s.probSlices()
+	if len(p) != len(m.Groups) {
+		return errModelMismatch
+	}
+	for i, g := range m.Groups {
+		if len(g.Probs) != len(p[i]) {
+			return fmt.Errorf("%w: group %d", errModelMismatch, i)
+		}
+		for _, v := range g.Probs {
+			if v == 0 || v >= 1<<probbits {
+				return fmt.Errorf(
+					"lzma: model probability %d out of range", v)
+			}
+		}
+	}
+	for i, g := range m.Groups {
+		for j, v := range g.Probs {
+			p[i][j] = prob(v)
+		}
+	}
+	s.state = uint32(m.State)
+	s.rep = m.Rep
+	return nil
+}
+
+// modelMagic and modelVersion start the binary encoding of a model
+// snapshot.
+var modelMagic = []byte("LZMM")
+
+const modelVersion = 1
+
+// MarshalBinary encodes the snapshot in a versioned binary format. It
+// consists of a magic string, the version, the properties byte, the state,
+// the rep distances, the number of probabilities and the probabilities in
+// the order of the groups, all in little-endian byte order.
+func (m *ModelSnapshot) MarshalBinary() ([]byte, error) {
+	if err := m.Properties.verify(); err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, g := range m.Groups {
+		n += len(g.Probs)
+	}
+	k := len(modelMagic)
+	data := make([]byte, k+23+2*n)
+	copy(data, modelMagic)
+	data[k] = modelVersion
+	data[k+1] = m.Properties.ToByte()
+	data[k+2] = byte(m.State)
+	p := data[k+3:]
+	for i, r := range m.Rep {
+		binary.LittleEndian.PutUint32(p[4*i:], r)
+	}
+	binary.LittleEndian.PutUint32(p[16:], uint32(n))
+	p = p[20:]
+	for _, g := range m.Groups {
+		for _, v := range g.Probs {
+			binary.LittleEndian.PutUint16(p, v)
+			p = p[2:]
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a snapshot encoded by MarshalBinary. The group
+// names and contexts are recreated from the properties.
+func (m *ModelSnapshot) UnmarshalBinary(data []byte) error {
+	k := len(modelMagic)
+	if len(data) < k+23 || string(data[:k]) != string(modelMagic) {
+		return errors.New("lzma: no model data")
+	}
+	if data[k] != modelVersion {
+		return fmt.Errorf("lzma: unsupported model version %d", data[k])
+	}
+	var p Properties
+	if err := p.fromByte(data[k+1]); err != nil {
+		return fmt.Errorf("lzma: model has %w", err)
+	}
+	s := newState(p)
+	t := s.snapshot()
+	t.State = int(data[k+2])
+	data = data[k+3:]
+	for i := range t.Rep {
+		t.Rep[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	n := int(binary.LittleEndian.Uint32(data[16:]))
+	data = data[20:]
+	if len(data) != 2*n {
+		return errors.New("lzma: model data has wrong length")
+	}
+	for _, g := range t.Groups {
+		if len(g.Probs) > n {
+			return errModelMismatch
+		}
+		for j := range g.Probs {
+			g.Probs[j] = binary.LittleEndian.Uint16(data)
+			data = data[2:]
+		}
+		n -= len(g.Probs)
+	}
+	if n != 0 {
+		return errModelMismatch
+	}
+	if err := s.restore(t); err != nil {
+		return err
+	}
+	*m = *t
+	return nil
+}
+
+// Model returns a snapshot of the probability model of the encoder. It is
+// nil while AutoProperties collects its sample.
+func (w *Writer) Model() *ModelSnapshot {
+	if w.e == nil {
+		return nil
+	}
+	return w.e.state.snapshot()
+}
+
+// Model returns a snapshot of the probability model of the decoder.
+func (r *Reader) Model() *ModelSnapshot {
+	return r.d.state.snapshot()
+}
+
+// ProbDiff describes a probability that differs between two snapshots.
+type ProbDiff struct {
+	Name    string         `json:"name"`
+	Context map[string]int `json:"context,omitempty"`
+	Index   int            `json:"index"`
+	Old     uint16         `json:"old"`
+	New     uint16         `json:"new"`
+}
+
+// ModelDiff lists the differences between two model snapshots. State and
+// Rep contain the old and the new value.
+type ModelDiff struct {
+	State [2]int       `json:"state"`
+	Rep   [2][4]uint32 `json:"rep"`
+	Probs []ProbDiff   `json:"probs"`
+}
+
+var errModelMismatch = errors.New("lzma: model snapshots have different layouts")
+
+// DiffModels computes the differences between the snapshots a and b, which
+// must have been taken from models with the same properties.
+func DiffModels(a, b *ModelSnapshot) (*ModelDiff, error) {
+	if a.Properties != b.Properties || len(a.Groups) != len(b.Groups) {
+		return nil, errModelMismatch
+	}
+	d := &ModelDiff{
+		State: [2]int{a.State, b.State},
+		Rep:   [2][4]uint32{a.Rep, b.Rep},
+	}
+	for i := range a.Groups {
+		ga, gb := &a.Groups[i], &b.Groups[i]
+		if ga.Name != gb.Name || len(ga.Probs) != len(gb.Probs) {
+			return nil, fmt.Errorf("%w: group %d", errModelMismatch, i)
+		}
+		for j, p := range ga.Probs {
+			if p == gb.Probs[j] {
+				continue
+			}
+			d.Probs = append(d.Probs, ProbDiff{
+				Name:    ga.Name,
+				Context: ga.Context,
+				Index:   j,
+				Old:     p,
+				New:     gb.Probs[j],
+			})
+		}
+	}
+	return d, nil
+}
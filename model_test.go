@@ -0,0 +1,158 @@
+package lzma
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func trainedModel(t *testing.T) *ModelSnapshot {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(testData(30000)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return w.Model()
+}
+
+func TestModelJSON(t *testing.T) {
+	m := trainedModel(t)
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var m2 ModelSnapshot
+	if err = json.Unmarshal(data, &m2); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(m, &m2) {
+		t.Fatalf("unmarshalled model differs")
+	}
+}
+
+// changedGroups returns the sorted names of the groups in d.
+func changedGroups(d *ModelDiff) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range d.Probs {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDiffModels(t *testing.T) {
+	m := trainedModel(t)
+	d, err := DiffModels(m, m)
+	if err != nil {
+		t.Fatalf("DiffModels: %v", err)
+	}
+	if len(d.Probs) != 0 || d.State[0] != d.State[1] {
+		t.Fatalf("identical models differ: %+v", d)
+	}
+
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{"abcdefgh", []string{"isMatch", "literal"}},
+		{"abcdefgh abcdefgh", []string{"isMatch", "isRep", "lenChoice",
+			"lenLow", "literal", "posModel", "posSlot"}},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		cfg := WriterConfig{Size: int64(len(tc.data))}
+		w, err := cfg.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("NewWriter: %v", err)
+		}
+		before := w.Model()
+		if _, err = io.WriteString(w, tc.data); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		d, err := DiffModels(before, w.Model())
+		if err != nil {
+			t.Fatalf("DiffModels: %v", err)
+		}
+		if got := changedGroups(d); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: changed groups %q; want %q", tc.data,
+				got, tc.want)
+		}
+	}
+
+	other := trainedModel(t)
+	other.Properties.LC = 0
+	if _, err = DiffModels(m, other); err == nil {
+		t.Errorf("DiffModels accepted models with different properties")
+	}
+}
+
+func TestModelMarshal(t *testing.T) {
+	m := trainedModel(t)
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	var m2 ModelSnapshot
+	if err = m2.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(m, &m2) {
+		t.Fatalf("unmarshalled model differs")
+	}
+
+	bad := [][]byte{
+		nil,
+		data[:len(data)-1],
+		append(append([]byte(nil), data...), 0, 0),
+		append([]byte("XXXX"), data[4:]...),
+		append(append([]byte(nil), data[:4]...), append([]byte{99}, data[5:]...)...),
+	}
+	for i, p := range bad {
+		var m3 ModelSnapshot
+		if err = m3.UnmarshalBinary(p); err == nil {
+			t.Errorf("case %d: UnmarshalBinary accepted bad data", i)
+		}
+	}
+}
+
+func TestModelRoundTrip(t *testing.T) {
+	m := trainedModel(t)
+	data := testData(5000)[100:]
+	plain := compressWith(t, WriterConfig{}, data)
+	lzma := compressWith(t, WriterConfig{Model: m}, data)
+	if len(lzma) >= len(plain) {
+		t.Errorf("trained model gives %d bytes; default model %d",
+			len(lzma), len(plain))
+	}
+	r, err := ReaderConfig{Model: m}.NewReader(bytes.NewReader(lzma))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decoded data differs")
+	}
+	if got, err = Decompress(nil, lzma); err == nil && bytes.Equal(got, data) {
+		t.Fatalf("stream decoded without the model")
+	}
+}
@@ -0,0 +1,118 @@
+package lzma
+
+import "fmt"
+
+// Op is a literal or a match operation of an LZ77 parse. A literal has a
+// Distance of zero and provides its value in Byte; its Len is 1. A match
+// copies Len bytes starting Distance bytes before the current position. A
+// match with length 1 is a short rep and must use the most recent
+// distance.
+type Op struct {
+	Byte     byte
+	Len      int
+	Distance int64
+}
+
+func (op Op) String() string {
+	if op.Distance == 0 {
+		return fmt.Sprintf("LIT 0x%02x", op.Byte)
+	}
+	return fmt.Sprintf("MATCH len %d dist %d", op.Len, op.Distance)
+}
+
+// WriteOps encodes the operations provided by the caller instead of the
+// operations found by the match algorithm. Data that has been written
+// before and is still buffered will be encoded first. Every match is checked
+// against the dictionary. The number of operations written is returned.
+func (w *Writer) WriteOps(ops []Op) (n int, err error) {
+	if w.e == nil {
+		if err = w.startEncoder(); err != nil {
+			return 0, err
+		}
+	}
+	e := w.e
+	if err = e.compress(all); err != nil {
+		return 0, err
+	}
+	for _, op := range ops {
+		var x operation
+		if op.Distance == 0 {
+			x = lit{op.Byte}
+		} else {
+			if err = e.checkMatch(op); err != nil {
+				return n, err
+			}
+			x = match{distance: op.Distance, n: op.Len}
+		}
+		if w.h.size >= 0 && e.Compressed()+int64(x.Len()) > w.h.size {
+			return n, ErrNoSpace
+		}
+		if err = e.writeOp(x); err != nil {
+			return n, err
+		}
+		switch x := x.(type) {
+		case lit:
+			if err = e.dict.WriteByte(x.b); err != nil {
+				return n, err
+			}
+		case match:
+			if err = e.dict.WriteMatch(x.distance, x.n); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+// checkMatch verifies that the match operation can be encoded at the
+// current position.
+func (e *encoder) checkMatch(op Op) error {
+	if op.Distance < minDistance || op.Distance > int64(e.dict.DictLen()) {
+		return fmt.Errorf("lzma: match distance %d outside of dictionary",
+			op.Distance)
+	}
+	if op.Len == 1 {
+		if uint32(op.Distance-minDistance) != e.state.rep[0] {
+			return fmt.Errorf("lzma: short rep distance %d is not rep0",
+				op.Distance)
+		}
+		return nil
+	}
+	if op.Len < minMatchLen || op.Len > maxMatchLen {
+		return fmt.Errorf("lzma: match length %d out of range", op.Len)
+	}
+	return nil
+}
+
+// discardWriter throws all data written away.
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func (discardWriter) WriteByte(c byte) error { return nil }
+
+// Tokenize returns the operations the match algorithm and the other
+// parameters of cfg produce for src. The Size, SizeInHeader and Trace fields
+// of cfg are ignored.
+func Tokenize(src []byte, cfg WriterConfig) ([]Op, error) {
+	var ops []Op
+	cfg.Size, cfg.SizeInHeader = 0, false
+	cfg.Trace = func(t TraceOp) {
+		if t.Kind == OpEOS {
+			return
+		}
+		ops = append(ops, Op{Byte: t.Byte, Len: t.Len, Distance: t.Distance})
+	}
+	w, err := cfg.NewWriter(discardWriter{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(src); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
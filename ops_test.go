@@ -0,0 +1,65 @@
+package lzma
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestTokenizeWriteOps(t *testing.T) {
+	data := testData(20000)
+	ops, err := Tokenize(data, WriterConfig{})
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if got := writeOps(t, ops); !bytes.Equal(got, data) {
+		t.Fatalf("decoded data differs")
+	}
+}
+
+// writeOps encodes ops with WriteOps and returns the decoded stream. It
+// checks that the operations cover the data.
+func writeOps(t *testing.T, ops []Op) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.WriteOps(ops); err != nil {
+		t.Fatalf("WriteOps: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	var n int
+	for _, op := range ops {
+		n += op.Len
+	}
+	if n != len(got) {
+		t.Fatalf("operations cover %d bytes; decoded %d", n, len(got))
+	}
+	return got
+}
+
+func TestOpString(t *testing.T) {
+	for _, tc := range []struct {
+		op   Op
+		want string
+	}{
+		{Op{Byte: 0x0a, Len: 1}, "LIT 0x0a"},
+		{Op{Len: 5, Distance: 12}, "MATCH len 5 dist 12"},
+	} {
+		if got := tc.op.String(); got != tc.want {
+			t.Errorf("got %q; want %q", got, tc.want)
+		}
+	}
+}
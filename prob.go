@@ -23,3 +23,7 @@ func (p prob) bound(r uint32) uint32 {
 func (p *prob) Encode(e *rangeEncoder, v uint32) error {
 	return e.EncodeBit(v, p)
 }
+
+func (p *prob) Decode(d *rangeDecoder) (uint32, error) {
+	return d.DecodeBit(p)
+}
@@ -1,6 +1,6 @@
 package lzma
 
-import "errors"
+import "fmt"
 
 // minLC and maxLC define the range for LC values.
 const (
@@ -26,16 +26,16 @@ type Properties struct {
 
 func (p *Properties) verify() error {
 	if p == nil {
-		return errors.New("lzma: properties are nil")
+		return &ConfigError{"Properties", "properties are nil"}
 	}
 	if p.LC < minLC || p.LC > maxLC {
-		return errors.New("lzma: lc out of range")
+		return &ConfigError{"LC", "lc out of range"}
 	}
 	if p.LP < minLP || p.LP > maxLP {
-		return errors.New("lzma: lp out of range")
+		return &ConfigError{"LP", "lp out of range"}
 	}
 	if p.PB < minPB || p.PB > maxPB {
-		return errors.New("lzma: pb out of range")
+		return &ConfigError{"PB", "pb out of range"}
 	}
 	return nil
 }
@@ -43,3 +43,16 @@ func (p *Properties) verify() error {
 func (p Properties) ToByte() byte {
 	return byte((p.PB*5+p.LP)*9 + p.LC)
 }
+
+func (p *Properties) fromByte(b byte) error {
+	x := int(b)
+	p.LC = x % 9
+	x /= 9
+	p.LP = x % 5
+	x /= 5
+	p.PB = x
+	if p.PB > maxPB {
+		return fmt.Errorf("invalid properties byte %#02x", b)
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package lzma
 
-import "io"
+import (
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+)
 
 type rangeEncoder struct {
 	lbw      *LimitedByteWriter
@@ -8,6 +13,9 @@ type rangeEncoder struct {
 	low      uint64
 	cacheLen int64
 	cache    byte
+	// number of low shifts and bytes written
+	shifts  int64
+	written int64
 }
 
 const maxInt64 = 1<<63 - 1
@@ -32,7 +40,44 @@ func (e *rangeEncoder) writeByte(c byte) error {
 	if e.Available() < 1 {
 		return ErrLimit
 	}
-	return e.lbw.WriteByte(c)
+	if err := e.lbw.WriteByte(c); err != nil {
+		return err
+	}
+	e.written++
+	return nil
+}
+
+// bitPos returns the number of bits produced by the encoder including the
+// fractional bits represented by the current range.
+func (e *rangeEncoder) bitPos() float64 {
+	return 8*float64(e.shifts) + 32 - math.Log2(float64(e.nrange))
+}
+
+// bitPosScale is the number of units per bit used by fixedBitPos.
+const bitPosScale = 1 << 8
+
+// log2Frac holds log2(1+i/64) in units of 1/bitPosScale bits.
+var log2Frac [64]int64
+
+func init() {
+	for i := range log2Frac {
+		log2Frac[i] = int64(math.Round(
+			bitPosScale * math.Log2(1+float64(i)/64)))
+	}
+}
+
+// fixedBitPos approximates bitPos in units of 1/bitPosScale bits. It
+// avoids the floating point logarithm and is used for the encoder
+// statistics, which are updated for every operation.
+func (e *rangeEncoder) fixedBitPos() int64 {
+	n := bits.Len32(e.nrange)
+	if n == 0 {
+		return (8*e.shifts + 32) * bitPosScale
+	}
+	// the six bits following the leading one of nrange
+	i := (e.nrange << uint(32-n)) >> 25 & 0x3f
+	l := int64(n-1)*bitPosScale + log2Frac[i]
+	return (8*e.shifts+32)*bitPosScale - l
 }
 
 func (e *rangeEncoder) DirectEncodeBit(b uint32) error {
@@ -95,6 +140,97 @@ func (e *rangeEncoder) shiftLow() error {
 		e.cache = byte(uint32(e.low) >> 24)
 	}
 	e.cacheLen++
+	e.shifts++
 	e.low = uint64(uint32(e.low) << 8)
 	return nil
 }
+
+var (
+	errFirstByte = errors.New("lzma: first byte of range coder not zero")
+	errRangeCode = errors.New("lzma: range coder code out of range")
+)
+
+type rangeDecoder struct {
+	br     io.ByteReader
+	nrange uint32
+	code   uint32
+	// number of bytes read
+	read int64
+}
+
+func newRangeDecoder(br io.ByteReader) (*rangeDecoder, error) {
+	d := &rangeDecoder{br: br, nrange: 0xffffffff}
+	b, err := d.br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	d.read++
+	if b != 0 {
+		return nil, errFirstByte
+	}
+	for i := 0; i < 4; i++ {
+		if err = d.updateCode(); err != nil {
+			return nil, err
+		}
+	}
+	if d.code >= d.nrange {
+		return nil, errRangeCode
+	}
+	return d, nil
+}
+
+func (d *rangeDecoder) possiblyAtEnd() bool {
+	return d.code == 0
+}
+
+func (d *rangeDecoder) DirectDecodeBit() (uint32, error) {
+	d.nrange >>= 1
+	d.code -= d.nrange
+	t := 0 - (d.code >> 31)
+	d.code += d.nrange & t
+	b := (t + 1) & 1
+
+	const top = 1 << 24
+	if d.nrange >= top {
+		return b, nil
+	}
+	d.nrange <<= 8
+	return b, d.updateCode()
+}
+
+func (d *rangeDecoder) DecodeBit(p *prob) (uint32, error) {
+	var b uint32
+	bound := p.bound(d.nrange)
+	if d.code < bound {
+		d.nrange = bound
+		p.inc()
+	} else {
+		d.code -= bound
+		d.nrange -= bound
+		p.dec()
+		b = 1
+	}
+
+	const top = 1 << 24
+	if d.nrange >= top {
+		return b, nil
+	}
+	d.nrange <<= 8
+	return b, d.updateCode()
+}
+
+func (d *rangeDecoder) updateCode() error {
+	b, err := d.br.ReadByte()
+	if err != nil {
+		return err
+	}
+	d.read++
+	d.code = (d.code << 8) | uint32(b)
+	return nil
+}
+
+// bitPos returns the position of the decoder in bits, which matches the
+// bit position of the encoder.
+func (d *rangeDecoder) bitPos() float64 {
+	return 8*float64(d.read-5) + 32 - math.Log2(float64(d.nrange))
+}
@@ -0,0 +1,205 @@
+package lzma
+
+import (
+	"bufio"
+	"io"
+)
+
+// Reader decompresses LZMA files. Concatenated streams are decoded one
+// after the other unless the configuration requests a single stream.
+type Reader struct {
+	cfg ReaderConfig
+	br  io.ByteReader
+	h   header
+	d   *decoder
+	err error
+}
+
+// ReaderConfig stores the parameters for the reader of the classic LZMA
+// format.
+type ReaderConfig struct {
+	DictCap int
+	// MaxDictCap limits the dictionary capacity a header may request.
+	MaxDictCap int
+	// MaxSize limits the size of the decompressed data.
+	MaxSize int64
+	// MaxRatio limits the ratio of decompressed to compressed bytes.
+	MaxRatio float64
+	// Trace is called for every operation read by the decoder.
+	Trace func(TraceOp)
+	// Salvage lets the reader return all data decoded before damaged or
+	// truncated input is detected. The error reporting the damage follows
+	// the salvaged data.
+	Salvage bool
+	// SingleStream stops decoding after the first stream or lzip member.
+	// Data following it is left unread and not checked.
+	SingleStream bool
+	// Model replaces the initial probability model of every LZMA stream.
+	// It must be the model used by the writer. Lzip files ignore it.
+	Model *ModelSnapshot
+	// MaxFrameSize limits the compressed size of a frame read by a
+	// FrameReader. If it is zero and MaxSize is set, the limit is
+	// CompressBound for MaxSize bytes without the header. Since that is
+	// an estimate, MaxFrameSize should be set if frames of crafted or
+	// extremely incompressible data must be accepted.
+	MaxFrameSize int64
+}
+
+func NewReader(lzma io.Reader) (*Reader, error) {
+	return ReaderConfig{}.NewReader(lzma)
+}
+
+func (c ReaderConfig) NewReader(lzma io.Reader) (*Reader, error) {
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	r := &Reader{cfg: c, br: byteReader(lzma)}
+	if err := r.startStream(0, 0); err != nil {
+		if err == io.EOF {
+			err = &CorruptInputError{Reason: ReasonTruncated,
+				Err: io.ErrUnexpectedEOF}
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// startStream reads the header of the stream following in compressed and
+// out decompressed bytes and creates its decoder. It returns io.EOF if
+// there is no further data.
+func (r *Reader) startStream(in, out int64) error {
+	c := &r.cfg
+	h, err := readHeader(r.br)
+	if err != nil {
+		if e, ok := err.(*CorruptInputError); ok {
+			e.Offset += in
+			e.Pos = out
+		}
+		return err
+	}
+	if err = c.checkDictCap(h.dictCap); err != nil {
+		return err
+	}
+	if h.size >= 0 {
+		if err = c.checkSize(out + h.size); err != nil {
+			return err
+		}
+	}
+	if h.dictCap < MinDictCap {
+		h.dictCap = MinDictCap
+	}
+	dictCap := h.dictCap
+	if c.DictCap > dictCap {
+		dictCap = c.DictCap
+	}
+
+	var dict *decoderDict
+	if r.d != nil && r.d.dict.capacity >= dictCap {
+		dict = r.d.dict
+		dict.Reset()
+	} else if dict, err = newDecoderDict(dictCap); err != nil {
+		return err
+	}
+	state, err := c.newState(h.properties)
+	if err != nil {
+		return err
+	}
+	d, err := newDecoder(r.br, state, dict, h.size, in+HeaderLen, out)
+	if err != nil {
+		return err
+	}
+	d.trace = c.Trace
+	d.lim = c.newLimits()
+	d.salvage = c.Salvage
+	r.h, r.d = h, d
+	return nil
+}
+
+// nextStream starts the stream following the current one. It returns
+// io.EOF if there is no further stream.
+func (r *Reader) nextStream() error {
+	in := r.d.in + r.d.rd.read
+	out := r.d.out + r.d.Decompressed()
+	if err := r.startStream(in, out); err != nil {
+		return trailingData(err, in, out)
+	}
+	return nil
+}
+
+// newState creates the state for a stream with the given properties.
+func (c *ReaderConfig) newState(p Properties) (*state, error) {
+	s := newState(p)
+	if c.Model != nil {
+		if err := s.restore(c.Model); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (c *ReaderConfig) fill() {
+	if c.DictCap == 0 {
+		c.DictCap = 8 * 1024 * 1024
+		if c.MaxDictCap > 0 && c.DictCap > c.MaxDictCap {
+			c.DictCap = c.MaxDictCap
+		}
+	}
+}
+
+func (c *ReaderConfig) Verify() error {
+	c.fill()
+	if c.DictCap < MinDictCap || int64(c.DictCap) > MaxDictCap {
+		return &ConfigError{"DictCap", "dictionary capacity is out of range"}
+	}
+	if c.MaxDictCap != 0 {
+		if c.MaxDictCap < MinDictCap || int64(c.MaxDictCap) > MaxDictCap {
+			return &ConfigError{"MaxDictCap",
+				"maximum dictionary capacity is out of range"}
+		}
+		if c.DictCap > c.MaxDictCap {
+			return &ConfigError{"DictCap",
+				"dictionary capacity exceeds maximum"}
+		}
+	}
+	if c.MaxSize < 0 {
+		return &ConfigError{"MaxSize", "negative maximum size"}
+	}
+	if c.MaxRatio < 0 {
+		return &ConfigError{"MaxRatio", "negative maximum ratio"}
+	}
+	if c.MaxFrameSize < 0 {
+		return &ConfigError{"MaxFrameSize", "negative maximum frame size"}
+	}
+	return nil
+}
+
+// EOSMarker reports whether the stream has been terminated by an
+// end-of-stream marker.
+func (r *Reader) EOSMarker() bool {
+	return r.d.eosMarker
+}
+
+func (r *Reader) Read(p []byte) (n int, err error) {
+	for n < len(p) && r.err == nil {
+		var k int
+		k, err = r.d.Read(p[n:])
+		n += k
+		if err != io.EOF {
+			return n, err
+		}
+		if r.cfg.SingleStream {
+			return n, io.EOF
+		}
+		r.err = r.nextStream()
+	}
+	return n, r.err
+}
+
+// byteReader converts r into an io.ByteReader. If r is already an
+// io.ByteReader it is returned unchanged.
+func byteReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
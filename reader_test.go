@@ -0,0 +1,146 @@
+package lzma
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"testing"
+)
+
+// decodeBoth decodes lzma with the Reader and with Decompress and checks
+// that both agree.
+func decodeBoth(t *testing.T, cfg ReaderConfig, lzma []byte) ([]byte, error) {
+	t.Helper()
+	var got []byte
+	r, err := cfg.NewReader(bytes.NewReader(lzma))
+	if err == nil {
+		got, err = io.ReadAll(r)
+	}
+	got2, err2 := cfg.Decompress(nil, lzma)
+	if (err == nil) != (err2 == nil) || !bytes.Equal(got, got2) {
+		t.Fatalf("Reader returned %d bytes and error %v; Decompress %d bytes and error %v",
+			len(got), err, len(got2), err2)
+	}
+	return got, err
+}
+
+func TestReaderStreams(t *testing.T) {
+	a, b := testData(3000), testData(50000)[1000:]
+	sized, err := Compress(nil, a, WriterConfig{})
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	eos := compressWith(t, WriterConfig{Matcher: BinaryTree}, b)
+	lzma := append(append(append([]byte(nil), sized...), eos...), sized...)
+	want := append(append(append([]byte(nil), a...), b...), a...)
+
+	got, err := decodeBoth(t, ReaderConfig{}, lzma)
+	if err != nil {
+		t.Fatalf("decoding concatenated streams: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("concatenated streams decoded incorrectly")
+	}
+
+	got, err = decodeBoth(t, ReaderConfig{SingleStream: true}, lzma)
+	if err != nil {
+		t.Fatalf("decoding single stream: %v", err)
+	}
+	if !bytes.Equal(got, a) {
+		t.Fatalf("single stream decoded incorrectly")
+	}
+
+	for _, garbage := range []string{"x", "garbage that is long enough"} {
+		_, err = decodeBoth(t, ReaderConfig{},
+			append(append([]byte(nil), eos...), garbage...))
+		var e *CorruptInputError
+		if !errors.As(err, &e) || e.Reason != ReasonTrailingData ||
+			e.Offset != int64(len(eos)) {
+			t.Fatalf("trailing %q: got error %v; want trailing garbage at offset %d",
+				garbage, err, len(eos))
+		}
+	}
+}
+
+func TestReaderMalformed(t *testing.T) {
+	data := testData(20000)
+	sized, err := Compress(nil, data, WriterConfig{})
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	eos := compressWith(t, WriterConfig{}, data)
+	modify := func(p []byte, i int, c byte) []byte {
+		p = append([]byte(nil), p...)
+		p[i] = c
+		return p
+	}
+	tests := []struct {
+		name   string
+		lzma   []byte
+		reason CorruptReason
+	}{
+		{"empty", nil, ReasonTruncated},
+		{"short header", eos[:5], ReasonTruncated},
+		{"properties", modify(eos, 0, 225), ReasonBadProperties},
+		{"first byte", modify(eos, HeaderLen, 1), ReasonFirstByte},
+		{"truncated sized", sized[:len(sized)/2], ReasonTruncated},
+		{"truncated eos", eos[:len(eos)-3], ReasonTruncated},
+		{"size too large", modify(sized, 5, sized[5]+1), ReasonTruncated},
+		{"size too small", modify(sized, 5, sized[5]-1), ReasonLengthPastSize},
+	}
+	for _, tc := range tests {
+		_, err := decodeBoth(t, ReaderConfig{}, tc.lzma)
+		var e *CorruptInputError
+		if !errors.As(err, &e) || e.Reason != tc.reason {
+			t.Errorf("%s: got error %v; want %v", tc.name, err, tc.reason)
+		}
+	}
+
+	// Random damage must be reported or change the output but never
+	// panic.
+	for i := HeaderLen; i < len(eos); i += 97 {
+		p := modify(eos, i, eos[i]^0x21)
+		got, err := decodeBoth(t, ReaderConfig{}, p)
+		if err == nil && bytes.Equal(got, data) {
+			t.Errorf("damage at offset %d not detected", i)
+		}
+	}
+}
+
+func TestReaderSalvage(t *testing.T) {
+	data := testData(100000)
+	eos := compressWith(t, WriterConfig{}, data)
+	cut := eos[:len(eos)/2]
+	got, err := decodeBoth(t, ReaderConfig{Salvage: true}, cut)
+	if !errors.Is(err, ErrCorruptInput) {
+		t.Fatalf("got error %v; want ErrCorruptInput", err)
+	}
+	if len(got) == 0 || !bytes.Equal(got, data[:len(got)]) {
+		t.Fatalf("salvaged %d bytes that are not a prefix of the input",
+			len(got))
+	}
+}
+
+func TestReaderXZ(t *testing.T) {
+	path, err := exec.LookPath("xz")
+	if err != nil {
+		t.Skip("xz not installed")
+	}
+	data := testData(200000)
+	for _, level := range []string{"-0", "-6e"} {
+		cmd := exec.Command(path, "--format=lzma", level, "-c")
+		cmd.Stdin = bytes.NewReader(data)
+		lzma, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("xz: %v", err)
+		}
+		got, err := decodeBoth(t, ReaderConfig{}, lzma)
+		if err != nil {
+			t.Fatalf("xz %s: decoding: %v", level, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("xz %s: decoded data differs", level)
+		}
+	}
+}
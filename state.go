@@ -85,6 +85,18 @@ func (s *state) updateStateShortRep() {
 	}
 }
 
+// repIndex returns the index of dist in the rep array or 4 if dist is not
+// a repeated distance.
+func (s *state) repIndex(dist uint32) int {
+	g := 0
+	for ; g < 4; g++ {
+		if s.rep[g] == dist {
+			break
+		}
+	}
+	return g
+}
+
 func (s *state) states(dictHead int64) (uint32, uint32, uint32) {
 	state1 := s.state
 	posState := uint32(dictHead) & s.posBitMask
@@ -0,0 +1,65 @@
+package lzma
+
+// Stats provides statistics about the operations chosen by the encoder.
+// The Bits fields give the number of range coder bits spent on each kind
+// of operation.
+type Stats struct {
+	Literals        int64
+	MatchedLiterals int64
+	Matches         int64
+	Reps            [4]int64
+	ShortReps       int64
+	// SkippedBytes counts the bytes encoded as literals without match
+	// search because of their entropy.
+	SkippedBytes int64
+
+	// LenHist counts the operations by match length; short reps are
+	// counted for length 1.
+	LenHist [maxMatchLen + 1]int64
+	// DistSlotHist counts the new matches by distance slot.
+	DistSlotHist [1 << posSlotBits]int64
+
+	InputBytes  int64
+	OutputBytes int64
+
+	// Properties and Matcher record the configuration of the encoder.
+	// Candidates counts the configurations tried by CompressBest.
+	Properties Properties
+	Matcher    MatchAlgorithm
+	Candidates int
+
+	LiteralBits        float64
+	MatchedLiteralBits float64
+	MatchBits          float64
+	RepBits            [4]float64
+	ShortRepBits       float64
+}
+
+func (s *Stats) addLiteral(matched bool, bits float64) {
+	if matched {
+		s.MatchedLiterals++
+		s.MatchedLiteralBits += bits
+		return
+	}
+	s.Literals++
+	s.LiteralBits += bits
+}
+
+// addMatch records the match m of kind k.
+func (s *Stats) addMatch(m match, k OpKind, bits float64) {
+	s.LenHist[m.n]++
+	switch k {
+	case OpMatch:
+		slot, _ := posSlot(uint32(m.distance - minDistance))
+		s.DistSlotHist[slot]++
+		s.Matches++
+		s.MatchBits += bits
+	case OpShortRep:
+		s.ShortReps++
+		s.ShortRepBits += bits
+	default:
+		g := k - OpRep0
+		s.Reps[g]++
+		s.RepBits[g] += bits
+	}
+}
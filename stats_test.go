@@ -0,0 +1,63 @@
+package lzma
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterStats(t *testing.T) {
+	data := testData(50000)
+	var buf bytes.Buffer
+	w, err := WriterConfig{Size: int64(len(data))}.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s := w.Stats()
+	if s.InputBytes != int64(len(data)) {
+		t.Errorf("InputBytes %d; want %d", s.InputBytes, len(data))
+	}
+	if s.OutputBytes != int64(buf.Len()) {
+		t.Errorf("OutputBytes %d; want %d", s.OutputBytes, buf.Len())
+	}
+
+	// Every input byte is covered by exactly one operation.
+	var ops, n int64
+	for k, c := range s.LenHist {
+		ops += c
+		n += int64(k) * c
+	}
+	n += s.Literals + s.MatchedLiterals
+	if n != int64(len(data)) {
+		t.Errorf("operations cover %d bytes; want %d", n, len(data))
+	}
+	matches := s.Matches + s.ShortReps
+	for _, c := range s.Reps {
+		matches += c
+	}
+	if ops != matches {
+		t.Errorf("LenHist counts %d matches; want %d", ops, matches)
+	}
+	if s.Matches == 0 || s.Literals == 0 {
+		t.Errorf("got %d literals and %d matches; want both",
+			s.Literals, s.Matches)
+	}
+
+	// Without an end marker only the five bytes flushed by Close are not
+	// accounted to operations.
+	bits := s.LiteralBits + s.MatchedLiteralBits + s.MatchBits +
+		s.ShortRepBits
+	for _, b := range s.RepBits {
+		bits += b
+	}
+	payload := float64(8 * (s.OutputBytes - HeaderLen))
+	if bits > payload || bits < payload-8*5 {
+		t.Errorf("operations use %.1f bits; payload has %.0f bits",
+			bits, payload)
+	}
+}
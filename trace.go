@@ -0,0 +1,108 @@
+package lzma
+
+import (
+	"fmt"
+	"io"
+)
+
+// OpKind identifies the kind of an LZMA operation.
+type OpKind byte
+
+// Kinds of operations. OpMatchedLit is a literal encoded directly after
+// a match using the byte at the rep0 distance as context.
+const (
+	OpLit OpKind = iota
+	OpMatchedLit
+	OpMatch
+	OpRep0
+	OpRep1
+	OpRep2
+	OpRep3
+	OpShortRep
+	OpEOS
+)
+
+var opKindStrings = [...]string{
+	OpLit:        "LIT",
+	OpMatchedLit: "MLIT",
+	OpMatch:      "MATCH",
+	OpRep0:       "REP0",
+	OpRep1:       "REP1",
+	OpRep2:       "REP2",
+	OpRep3:       "REP3",
+	OpShortRep:   "SHORTREP",
+	OpEOS:        "EOS",
+}
+
+func (k OpKind) String() string {
+	if int(k) < len(opKindStrings) {
+		return opKindStrings[k]
+	}
+	return "unknown"
+}
+
+// matchKind returns the kind of the match m for the rep index g computed
+// before the match has been encoded.
+func matchKind(m match, g int) OpKind {
+	switch {
+	case g == 4:
+		return OpMatch
+	case g == 0 && m.n == 1:
+		return OpShortRep
+	}
+	return OpRep0 + OpKind(g)
+}
+
+// TraceOp describes an operation chosen by the encoder or read by the
+// decoder. Rep is the index of the repeated distance used or -1. LitState
+// and MatchByte provide the context of literals. BitPos is the position of
+// the operation in the range coder stream and Bits gives the number of
+// range coder bits spent for the operation.
+type TraceOp struct {
+	Kind        OpKind
+	Pos         int64
+	Byte        byte
+	Len         int
+	Distance    int64
+	Rep         int
+	StateBefore int
+	StateAfter  int
+	LitState    int
+	MatchByte   byte
+	BitPos      float64
+	Bits        float64
+}
+
+// repOf returns the rep index used by an operation of kind k or -1.
+func repOf(k OpKind) int {
+	switch {
+	case k == OpShortRep:
+		return 0
+	case k >= OpRep0 && k <= OpRep3:
+		return int(k - OpRep0)
+	}
+	return -1
+}
+
+func (op TraceOp) String() string {
+	switch op.Kind {
+	case OpEOS:
+		return fmt.Sprintf("%d %s state %d bits %.2f", op.Pos, op.Kind,
+			op.StateBefore, op.Bits)
+	case OpLit, OpMatchedLit:
+		return fmt.Sprintf("%d %s 0x%02x state %d->%d bits %.2f",
+			op.Pos, op.Kind, op.Byte, op.StateBefore, op.StateAfter,
+			op.Bits)
+	}
+	return fmt.Sprintf("%d %s len %d dist %d rep %d state %d->%d bits %.2f",
+		op.Pos, op.Kind, op.Len, op.Distance, op.Rep, op.StateBefore,
+		op.StateAfter, op.Bits)
+}
+
+// TraceTo returns a trace function for WriterConfig that writes every
+// operation as a line to w. Write errors are ignored.
+func TraceTo(w io.Writer) func(TraceOp) {
+	return func(op TraceOp) {
+		fmt.Fprintln(w, op)
+	}
+}
@@ -0,0 +1,74 @@
+package lzma
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// replay reconstructs the data encoded by the traced operations.
+func replay(t *testing.T, ops []TraceOp) []byte {
+	t.Helper()
+	var out []byte
+	for _, op := range ops {
+		if op.Pos != int64(len(out)) {
+			t.Fatalf("op %v at position %d; want %d", op, op.Pos,
+				len(out))
+		}
+		switch op.Kind {
+		case OpLit, OpMatchedLit:
+			out = append(out, op.Byte)
+		case OpEOS:
+		default:
+			if op.Distance < 1 || op.Distance > int64(len(out)) {
+				t.Fatalf("op %v: invalid distance", op)
+			}
+			for i := 0; i < op.Len; i++ {
+				out = append(out, out[int64(len(out))-op.Distance])
+			}
+		}
+	}
+	return out
+}
+
+func TestTraceReplay(t *testing.T) {
+	data := testData(20000)
+	var ops []TraceOp
+	var lines bytes.Buffer
+	trace := TraceTo(&lines)
+	compressWith(t, WriterConfig{Trace: func(op TraceOp) {
+		ops = append(ops, op)
+		trace(op)
+	}}, data)
+	if got := replay(t, ops); !bytes.Equal(got, data) {
+		t.Fatal("replayed trace differs from input")
+	}
+	s := strings.Split(strings.TrimSuffix(lines.String(), "\n"), "\n")
+	if len(s) != len(ops) {
+		t.Fatalf("TraceTo wrote %d lines; want %d", len(s), len(ops))
+	}
+	for i, op := range ops {
+		if s[i] != op.String() {
+			t.Fatalf("line %d: %q; want %q", i, s[i], op.String())
+		}
+	}
+}
+
+func TestTraceOpString(t *testing.T) {
+	tests := []struct {
+		op   TraceOp
+		want string
+	}{
+		{TraceOp{Kind: OpLit, Pos: 3, Byte: 0x0a, StateAfter: 1,
+			Bits: 2.5}, "3 LIT 0x0a state 0->1 bits 2.50"},
+		{TraceOp{Kind: OpMatchedLit, Byte: 0}, "0 MLIT 0x00 state 0->0 bits 0.00"},
+		{TraceOp{Kind: OpRep1, Pos: 9, Len: 4, Distance: 2, Rep: 1,
+			StateBefore: 7, StateAfter: 8},
+			"9 REP1 len 4 dist 2 rep 1 state 7->8 bits 0.00"},
+	}
+	for _, tc := range tests {
+		if got := tc.op.String(); got != tc.want {
+			t.Errorf("got %q; want %q", got, tc.want)
+		}
+	}
+}
@@ -20,6 +20,18 @@ func (tc *treeCodec) Encode(e *rangeEncoder, v uint32) error {
 	return nil
 }
 
+func (tc *treeCodec) Decode(d *rangeDecoder) (uint32, error) {
+	m := uint32(1)
+	for j := 0; j < int(tc.bits); j++ {
+		b, err := d.DecodeBit(&tc.probs[m])
+		if err != nil {
+			return 0, err
+		}
+		m = (m << 1) | b
+	}
+	return m - (1 << uint(tc.bits)), nil
+}
+
 type treeReverseCodec struct {
 	probTree
 }
@@ -40,6 +52,20 @@ func (tc *treeReverseCodec) Encode(v uint32, e *rangeEncoder) error {
 	return nil
 }
 
+func (tc *treeReverseCodec) Decode(d *rangeDecoder) (uint32, error) {
+	var v uint32
+	m := uint32(1)
+	for j := uint(0); j < uint(tc.bits); j++ {
+		b, err := d.DecodeBit(&tc.probs[m])
+		if err != nil {
+			return 0, err
+		}
+		m = (m << 1) | b
+		v |= b << j
+	}
+	return v, nil
+}
+
 type probTree struct {
 	probs []prob
 	bits  byte
@@ -0,0 +1,166 @@
+package lzma
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Format identifies a compressed file format.
+type Format int
+
+// Supported formats.
+const (
+	FormatLZMA Format = iota + 1
+	FormatLzip
+	FormatXZ
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatLZMA:
+		return "lzma"
+	case FormatLzip:
+		return "lzip"
+	case FormatXZ:
+		return "xz"
+	}
+	return "unknown"
+}
+
+// xzMagic starts every xz file.
+var xzMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+
+var errXZ = errors.New("lzma: xz format not supported")
+
+// detectFormat identifies the format of the file read by br without
+// consuming any bytes. Files without magic bytes are classic LZMA files.
+func detectFormat(br *bufio.Reader) Format {
+	p, _ := br.Peek(len(xzMagic))
+	switch {
+	case bytes.HasPrefix(p, lzipMagic):
+		return FormatLzip
+	case bytes.HasPrefix(p, xzMagic):
+		return FormatXZ
+	}
+	return FormatLZMA
+}
+
+// Info summarizes a verified file.
+type Info struct {
+	Format       Format
+	Compressed   int64
+	Uncompressed int64
+	// Check names the integrity check of the format.
+	Check string
+	// Members counts the LZMA streams or lzip members.
+	Members int
+	// EOSMarker reports whether all streams are terminated by an EOS
+	// marker.
+	EOSMarker bool
+}
+
+// Ratio returns the compressed size relative to the uncompressed size.
+func (i Info) Ratio() float64 {
+	if i.Uncompressed == 0 {
+		return 0
+	}
+	return float64(i.Compressed) / float64(i.Uncompressed)
+}
+
+// verifyMaxDictCap is the largest dictionary accepted by Verify. It is the
+// dictionary size of the highest presets of xz and lzip.
+const verifyMaxDictCap = 64 << 20
+
+// Verify decodes the .lzma or .lz file read from r completely and checks
+// sizes, checksums and the termination of the streams. The decompressed
+// data is discarded without being buffered outside of the dictionary.
+// Files requiring a dictionary larger than 64 MiB are rejected with a
+// DictCapLimitError; ReaderConfig.VerifyFile supports other limits.
+func Verify(r io.Reader) (Info, error) {
+	return ReaderConfig{MaxDictCap: verifyMaxDictCap}.VerifyFile(r)
+}
+
+// VerifyFile verifies the file read from r like Verify, but applies the
+// limits of the configuration. The fields DictCap and Salvage are ignored.
+func (c ReaderConfig) VerifyFile(r io.Reader) (Info, error) {
+	// The dictionary is sized by the header alone.
+	c.DictCap = MinDictCap
+	c.Salvage = false
+	if err := c.Verify(); err != nil {
+		return Info{}, err
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	info := Info{Format: detectFormat(br)}
+	var err error
+	switch info.Format {
+	case FormatLzip:
+		err = verifyLzip(c, br, &info)
+	case FormatXZ:
+		err = errXZ
+	default:
+		err = verifyLZMA(c, br, &info)
+	}
+	return info, err
+}
+
+func verifyLZMA(c ReaderConfig, br *bufio.Reader, info *Info) error {
+	info.Check = "none"
+	r, err := c.NewReader(br)
+	if err != nil {
+		return err
+	}
+	info.EOSMarker = true
+	for {
+		err = r.d.verify(nil)
+		info.Compressed = r.d.in + r.d.rd.read
+		info.Uncompressed = r.d.out + r.d.Decompressed()
+		if err != nil {
+			return err
+		}
+		info.Members++
+		info.EOSMarker = info.EOSMarker && r.d.eosMarker
+		if err = r.nextStream(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func verifyLzip(c ReaderConfig, br *bufio.Reader, info *Info) error {
+	info.Check = "CRC32"
+	info.EOSMarker = true
+	r := &LzipReader{cfg: c, br: &countingByteReader{br: br}}
+	if err := r.startMember(); err != nil {
+		return err
+	}
+	crc := func(p []byte) {
+		r.crc = crc32.Update(r.crc, crc32.IEEETable, p)
+	}
+	for {
+		err := r.d.verify(crc)
+		if err == nil {
+			err = r.finishMember()
+		}
+		if err != nil {
+			info.Compressed = r.in + r.br.n
+			info.Uncompressed = r.out + r.d.Decompressed()
+			return err
+		}
+		info.Members++
+		info.Compressed, info.Uncompressed = r.in, r.out
+		if err = r.nextMember(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package lzma
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	data := testData(200000)
+	lzma := compressWith(t, WriterConfig{DictCap: 64 << 10}, data)
+	lzma = append(lzma, lzma...)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	info, err := Verify(bytes.NewReader(lzma))
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if info.Format != FormatLZMA || info.Members != 2 ||
+		info.Compressed != int64(len(lzma)) ||
+		info.Uncompressed != 2*int64(len(data)) || !info.EOSMarker {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("Verify allocated %d bytes for a 64 KiB dictionary", n)
+	}
+
+	lzma[len(lzma)/4] ^= 0x40
+	if _, err = Verify(bytes.NewReader(lzma)); !errors.Is(err, ErrCorruptInput) {
+		t.Fatalf("Verify of corrupt file got error %v; want ErrCorruptInput",
+			err)
+	}
+}
+
+func TestVerifyDictCap(t *testing.T) {
+	// header with the maximum dictionary capacity followed by an empty
+	// stream
+	hostile := append([]byte{0x5d, 0xff, 0xff, 0xff, 0xff},
+		bytes.Repeat([]byte{0xff}, 8)...)
+	hostile = append(hostile, make([]byte, 5)...)
+	lz := lzipCompress(t, LzipWriterConfig{DictCap: 1 << 16}, testData(1000))
+	// lzip header claiming a dictionary of 128 MiB
+	hostileLz := append([]byte(nil), lz...)
+	hostileLz[5] = 27
+	for _, p := range [][]byte{hostile, hostileLz} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := Verify(bytes.NewReader(p))
+		runtime.ReadMemStats(&after)
+		var e *DictCapLimitError
+		if !errors.As(err, &e) {
+			t.Fatalf("Verify got error %v; want DictCapLimitError", err)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("Verify allocated %d bytes", n)
+		}
+	}
+
+	if _, err := Verify(bytes.NewReader(lz)); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	cfg := ReaderConfig{MaxDictCap: MinDictCap}
+	_, err := cfg.VerifyFile(bytes.NewReader(lz))
+	var e *DictCapLimitError
+	if !errors.As(err, &e) {
+		t.Fatalf("VerifyFile got error %v; want DictCapLimitError", err)
+	}
+}
@@ -2,7 +2,6 @@ package lzma
 
 import (
 	"bufio"
-	"errors"
 	"io"
 )
 
@@ -18,6 +17,16 @@ type Writer struct {
 	bw  io.ByteWriter
 	buf *bufio.Writer
 	e   *encoder
+	// ws receives the size on Close if it is not nil; start gives the
+	// offset of the header
+	ws    io.WriteSeeker
+	start int64
+	// cfg is the verified configuration; sample collects the data used
+	// to select the properties before the encoder is created
+	cfg    WriterConfig
+	sample []byte
+	// entries records the entries started by NextEntry
+	entries []Entry
 }
 
 type WriterConfig struct {
@@ -28,6 +37,28 @@ type WriterConfig struct {
 	SizeInHeader bool
 	Size         int64
 	EOSMarker    bool
+	// Trace is called for every operation written by the encoder.
+	Trace func(TraceOp)
+	// PatchSize requires an io.WriteSeeker as output. Close seeks back to
+	// the header and writes the actual size into it. The EOS marker is
+	// only written if EOSMarker is set.
+	PatchSize bool
+	// Model replaces the initial probability model. The file can only be
+	// decoded by a reader using the same model.
+	Model *ModelSnapshot
+	// AutoProperties selects the properties by trial-compressing the
+	// first data written; see SelectProperties. The writer collects up to
+	// 192 KiB before it writes the header.
+	AutoProperties bool
+	// EntropyThreshold disables the match search for blocks of 4 KiB whose
+	// order-0 entropy reaches the threshold in bits per byte. Such data,
+	// for instance JPEG images, is encoded as literals. A block is only
+	// checked once it is completely buffered, so the result doesn't depend
+	// on the sizes of the writes; the last block may be shorter. Blocks
+	// are limited to BufSize. Zero disables the check; 7.5 is a
+	// reasonable value. Storing incompressible data in uncompressed chunks
+	// requires LZMA2 and is not supported yet.
+	EntropyThreshold float64
 }
 
 func NewWriter(lzma io.Writer) (*Writer, error) {
@@ -39,6 +70,17 @@ func (c WriterConfig) NewWriter(lzma io.Writer) (*Writer, error) {
 		return nil, err
 	}
 	w := &Writer{h: c.header()}
+	if c.PatchSize {
+		var ok bool
+		if w.ws, ok = lzma.(io.WriteSeeker); !ok {
+			return nil, &ConfigError{"PatchSize",
+				"PatchSize requires an io.WriteSeeker"}
+		}
+		var err error
+		if w.start, err = w.ws.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
 
 	var ok bool
 	w.bw, ok = lzma.(io.ByteWriter)
@@ -46,12 +88,88 @@ func (c WriterConfig) NewWriter(lzma io.Writer) (*Writer, error) {
 		w.buf = bufio.NewWriter(lzma)
 		w.bw = w.buf
 	}
-	state := newState(w.h.properties)
-	m, err := c.Matcher.new(w.h.dictCap)
+	w.cfg = c
+	if c.AutoProperties {
+		return w, nil
+	}
+	var err error
+	if w.e, err = c.newEncoder(w.bw); err != nil {
+		return nil, err
+	}
+
+	if err = w.writeHeader(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// startEncoder selects the properties for the collected sample, writes the
+// header and encodes the sample.
+func (w *Writer) startEncoder() error {
+	c := w.cfg
+	if err := c.autoProperties(w.sample); err != nil {
+		return err
+	}
+	w.h = c.header()
+	var err error
+	if w.e, err = c.newEncoder(w.bw); err != nil {
+		return err
+	}
+	if err = w.writeHeader(); err != nil {
+		return err
+	}
+	_, err = w.e.Write(w.sample)
+	w.sample = nil
+	return err
+}
+
+// writeSample collects data until enough is available to select the
+// properties. The remaining data is passed to the encoder, so the sample
+// never exceeds autoSampleLen bytes.
+func (w *Writer) writeSample(p []byte) (int, error) {
+	var err error
+	if w.h.size >= 0 {
+		m := w.h.size - int64(len(w.sample))
+		if m < int64(len(p)) {
+			p = p[:m]
+			err = ErrNoSpace
+		}
+	}
+	if w.sample == nil {
+		w.sample = make([]byte, 0, autoSampleLen)
+	}
+	k := autoSampleLen - len(w.sample)
+	if k > len(p) {
+		k = len(p)
+	}
+	w.sample = append(w.sample, p[:k]...)
+	if len(w.sample) < autoSampleLen {
+		return k, err
+	}
+	if serr := w.startEncoder(); serr != nil {
+		return k, serr
+	}
+	n, werr := w.Write(p[k:])
+	if werr != nil {
+		err = werr
+	}
+	return k + n, err
+}
+
+// newEncoder creates the encoder for the configuration, which must have
+// been verified.
+func (c *WriterConfig) newEncoder(bw io.ByteWriter) (*encoder, error) {
+	state := newState(*c.Properties)
+	if c.Model != nil {
+		if err := state.restore(c.Model); err != nil {
+			return nil, err
+		}
+	}
+	m, err := c.Matcher.new(c.DictCap)
 	if err != nil {
 		return nil, err
 	}
-	dict, err := newEncoderDict(w.h.dictCap, c.BufSize, m)
+	dict, err := newEncoderDict(c.DictCap, c.BufSize, m)
 	if err != nil {
 		return nil, err
 	}
@@ -59,19 +177,23 @@ func (c WriterConfig) NewWriter(lzma io.Writer) (*Writer, error) {
 	if c.EOSMarker {
 		flags = eosMarker
 	}
-	if w.e, err = newEncoder(w.bw, state, dict, flags); err != nil {
-		return nil, err
-	}
-
-	if err = w.writeHeader(); err != nil {
+	e, err := newEncoder(bw, state, dict, flags)
+	if err != nil {
 		return nil, err
 	}
-	return w, nil
+	e.trace = c.Trace
+	e.entropyThreshold = c.EntropyThreshold
+	return e, nil
 }
 
 func (c *WriterConfig) fill() {
 	if c.Properties == nil {
-		c.Properties = &Properties{LC: 3, LP: 0, PB: 2}
+		if c.Model != nil {
+			p := c.Model.Properties
+			c.Properties = &p
+		} else {
+			c.Properties = &Properties{LC: 3, LP: 0, PB: 2}
+		}
 	}
 	if c.DictCap == 0 {
 		c.DictCap = 8 * 1024 * 1024
@@ -82,34 +204,50 @@ func (c *WriterConfig) fill() {
 	if c.Size > 0 {
 		c.SizeInHeader = true
 	}
-	if !c.SizeInHeader {
+	if !c.SizeInHeader && !c.PatchSize {
 		c.EOSMarker = true
 	}
 }
 
 func (c *WriterConfig) Verify() error {
-	c.fill() // init?
 	if c == nil {
-		return errors.New("lzma: WriterConfig is nil")
+		return &ConfigError{"WriterConfig", "WriterConfig is nil"}
 	}
+	c.fill()
 	if c.Properties == nil {
-		return errors.New("lzma: WriterConfig has no Properties set")
+		return &ConfigError{"Properties", "WriterConfig has no Properties set"}
 	}
 	if err := c.Properties.verify(); err != nil {
 		return err
 	}
+	if c.AutoProperties && c.Model != nil {
+		return &ConfigError{"AutoProperties",
+			"AutoProperties can't be combined with a Model"}
+	}
+	if c.EntropyThreshold < 0 || c.EntropyThreshold > 8 {
+		return &ConfigError{"EntropyThreshold",
+			"entropy threshold must be in the range 0 to 8"}
+	}
+	if c.Model != nil && c.Model.Properties != *c.Properties {
+		return &ConfigError{"Model",
+			"model properties differ from Properties"}
+	}
 	if c.DictCap < MinDictCap || int64(c.DictCap) > MaxDictCap {
-		return errors.New("lzma: dictionary capacity is out of range")
+		return &ConfigError{"DictCap", "dictionary capacity is out of range"}
 	}
 	if c.BufSize < maxMatchLen {
-		return errors.New("lzma: lookahead buffer size too small")
+		return &ConfigError{"BufSize", "lookahead buffer size too small"}
 	}
 	if c.SizeInHeader {
 		if c.Size < 0 {
-			return errors.New("lzma: negative size not supported")
+			return &ConfigError{"Size", "negative size not supported"}
+		}
+		if c.PatchSize {
+			return &ConfigError{"PatchSize",
+				"PatchSize conflicts with SizeInHeader"}
 		}
-	} else if !c.EOSMarker {
-		return errors.New("lzma: EOS marker is required")
+	} else if !c.EOSMarker && !c.PatchSize {
+		return &ConfigError{"EOSMarker", "EOS marker is required"}
 	}
 	if err := c.Matcher.verify(); err != nil {
 		return err
@@ -140,6 +278,9 @@ func (w *Writer) writeHeader() error {
 }
 
 func (w *Writer) Write(p []byte) (int, error) {
+	if w.e == nil {
+		return w.writeSample(p)
+	}
 	var err error
 	if w.h.size >= 0 {
 		m := w.h.size
@@ -162,6 +303,11 @@ func (w *Writer) Write(p []byte) (int, error) {
 }
 
 func (w *Writer) Close() error {
+	if w.e == nil {
+		if err := w.startEncoder(); err != nil {
+			return err
+		}
+	}
 	if w.h.size >= 0 {
 		n := w.e.Compressed() + int64(w.e.dict.Buffered())
 		if n != w.h.size {
@@ -175,5 +321,45 @@ func (w *Writer) Close() error {
 			err = ferr 
 		}
 	}
+	if err == nil && w.ws != nil {
+		err = w.patchSize()
+	}
 	return err 
-}
\ No newline at end of file
+}
+
+// patchSize writes the number of bytes encoded into the header and moves
+// the offset of the output back to the end of the stream.
+func (w *Writer) patchSize() error {
+	end, err := w.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	w.h.size = w.e.Compressed()
+	data, err := w.h.marshalBinary()
+	if err != nil {
+		return err
+	}
+	if _, err = w.ws.Seek(w.start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = w.ws.Write(data); err != nil {
+		return err
+	}
+	_, err = w.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// Stats returns the statistics for the data encoded so far. OutputBytes
+// includes the header but may contain bytes that are still buffered. The
+// statistics are empty while AutoProperties collects its sample.
+func (w *Writer) Stats() Stats {
+	if w.e == nil {
+		return Stats{}
+	}
+	s := w.e.stats
+	s.InputBytes = w.e.Compressed()
+	s.OutputBytes = HeaderLen + w.e.re.written
+	s.Properties = w.h.properties
+	s.Matcher = w.cfg.Matcher
+	return s
+}
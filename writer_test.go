@@ -0,0 +1,122 @@
+package lzma
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testData returns n bytes of text with many short and long repetitions.
+func testData(n int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < n; i++ {
+		fmt.Fprintf(&buf, "%d: the quick brown fox %x jumps over the lazy dog %d\n",
+			i, i*i%97, i%13)
+		if i%17 == 0 {
+			buf.WriteString("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+		}
+	}
+	return buf.Bytes()[:n]
+}
+
+// xzDecode decompresses an .lzma file with the xz tool. The test is skipped
+// if xz is not installed.
+func xzDecode(t *testing.T, lzma []byte) []byte {
+	t.Helper()
+	path, err := exec.LookPath("xz")
+	if err != nil {
+		t.Skip("xz not installed")
+	}
+	cmd := exec.Command(path, "--format=lzma", "-dc")
+	cmd.Stdin = bytes.NewReader(lzma)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("xz: %v", err)
+	}
+	return out
+}
+
+func compressWith(t *testing.T, cfg WriterConfig, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := cfg.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriterXZ(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 70000} {
+		data := testData(n)
+		lzma := compressWith(t, WriterConfig{}, data)
+		if got := xzDecode(t, lzma); !bytes.Equal(got, data) {
+			t.Fatalf("n=%d: xz output differs from input", n)
+		}
+	}
+}
+
+func TestWriterPatchSize(t *testing.T) {
+	data := testData(30000)
+	f, err := os.Create(filepath.Join(t.TempDir(), "patch.lzma"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// The stream doesn't need to start at offset zero.
+	if _, err = f.Write([]byte("prefix")); err != nil {
+		t.Fatal(err)
+	}
+	w, err := WriterConfig{PatchSize: true}.NewWriter(f)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	p, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lzma := p[len("prefix"):]
+	if n := binary.LittleEndian.Uint64(lzma[5:]); n != uint64(len(data)) {
+		t.Fatalf("header size %d; want %d", n, len(data))
+	}
+	r, err := NewReader(bytes.NewReader(lzma))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decoded data differs")
+	}
+	if r.EOSMarker() {
+		t.Errorf("stream has an EOS marker")
+	}
+	if got = xzDecode(t, lzma); !bytes.Equal(got, data) {
+		t.Fatalf("xz output differs from input")
+	}
+
+	if _, err = (WriterConfig{PatchSize: true}).NewWriter(&bytes.Buffer{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("PatchSize without io.WriteSeeker: %v; want ErrInvalidConfig",
+			err)
+	}
+}